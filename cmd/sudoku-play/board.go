@@ -0,0 +1,99 @@
+package main
+
+/* The game as the terminal sees it: a sudoku_game.Session plus the cursor,
+ * the input mode and a message line.
+ */
+
+import (
+  "fmt"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_format"
+  "github.com/wplapper/go-sudoku3/sudoku_game"
+)
+
+const Nine = 9
+
+type board struct {
+    session *sudoku_game.Session
+    cursor  int
+    pencil  bool   // digits toggle pencil marks instead of entering
+    message string // shown below the grid
+}
+
+func new_board(puzzle string) (*board, error) {
+    session, err := sudoku_game.New_session(puzzle)
+    if err != nil {
+        return nil, err
+    }
+    return &board{session: session}, nil
+}
+
+func (b *board) move(dr, dc int) {
+    r := (b.cursor / 9 + dr + Nine) % Nine
+    c := (b.cursor % 9 + dc + Nine) % Nine
+    b.cursor = r * 9 + c
+}
+
+func (b *board) enter(digit int) {
+    // enter 'digit' or toggle its pencil mark, depending on the mode
+    var err error
+    if b.pencil {
+        err = b.session.Toggle_mark(b.cursor, digit)
+    } else {
+        err = b.session.Enter(b.cursor, digit)
+    }
+    b.report(err)
+}
+
+func (b *board) clear() {
+    b.report(b.session.Erase(b.cursor))
+}
+
+func (b *board) undo() {
+    if ! b.session.Undo() {
+        b.message = "nothing to undo"
+    }
+}
+
+func (b *board) redo() {
+    if ! b.session.Redo() {
+        b.message = "nothing to redo"
+    }
+    b.report(nil)
+}
+
+func (b *board) hint() {
+    // point out a wrong entry first, otherwise the next step of the solver
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if b.session.Wrong(cell) {
+            b.cursor  = cell
+            b.message = fmt.Sprintf("%d in %s is wrong",
+                b.session.Value(cell), sudoku_format.Cell_name(cell))
+            return
+        }
+    }
+    if b.session.Solved() {
+        b.message = "solved already"
+        return
+    }
+
+    step, ok := b.session.Hint()
+    if ! ok {
+        b.message = "no logical step, guessing is needed"
+        return
+    }
+    if step.Cell >= 0 {
+        b.cursor = step.Cell
+    }
+    b.message = "hint: " + step.String()
+}
+
+func (b *board) report(err error) {
+    switch {
+    case err != nil:
+        b.message = err.Error()
+    case b.session.Solved():
+        b.message = "solved, well done!"
+    }
+}
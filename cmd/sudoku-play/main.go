@@ -0,0 +1,118 @@
+package main
+
+/* Play sudoku in the terminal.
+ *
+ *   sudoku-play [-n number] [-symmetric] [file]
+ *
+ * Without a file a new puzzle is generated. From a file, puzzle 'number'
+ * is played (1 for the first one), in any format sudoku_parser understands.
+ *
+ * Keys: arrows or hjkl move the cursor, 1-9 enter a digit (or toggle
+ * a pencil mark in pencil mode, switched with 'p'), 0, '.', space or
+ * delete clear a cell, '?' asks for a hint, 'u' and 'r' undo and redo,
+ * escape clears the message, 'q' quits. Digits which clash with
+ * a neighbour are shown on red.
+ */
+
+import (
+  "flag"
+  "fmt"
+  "math/rand"
+  "os"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_generator"
+  "github.com/wplapper/go-sudoku3/sudoku_parser"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+func main() {
+    number    := flag.Int("n", 1, "number of the puzzle in the file")
+    symmetric := flag.Bool("symmetric", false,
+        "generate a puzzle with symmetric givens")
+    flag.Parse()
+
+    sudoku_solver.Setup_solver_once()
+    puzzle, err := load(flag.Args(), *number, *symmetric)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku-play: %v\n", err)
+        os.Exit(2)
+    }
+    b, err := new_board(puzzle)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku-play: %v\n", err)
+        os.Exit(1)
+    }
+
+    t, err := open_terminal()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku-play: no terminal: %v\n", err)
+        os.Exit(2)
+    }
+    defer t.close()
+    play(t, b)
+}
+
+func load(args [] string, number int, symmetric bool) (string, error) {
+    switch len(args) {
+    case 0:
+        rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+        puzzle, _ := sudoku_generator.Generate(rng, symmetric)
+        return puzzle, nil
+    case 1:
+        puzzles, err := sudoku_parser.Parse_file(args[0])
+        if err != nil {
+            return "", err
+        }
+        if number < 1 || number > len(puzzles) {
+            return "", fmt.Errorf("%s: no puzzle %d, there are %d", args[0],
+                number, len(puzzles))
+        }
+        return puzzles[number - 1], nil
+    }
+    return "", fmt.Errorf("usage: sudoku-play [-n number] [-symmetric] [file]")
+}
+
+func play(t *terminal, b *board) {
+    // the clock in the status line goes on while no key is pressed
+    ticker := time.NewTicker(time.Second)
+    defer ticker.Stop()
+    t.draw(b)
+    for {
+        var key int
+        select {
+        case char, ok := <-t.bytes:
+            key = t.key(char, ok)
+        case <-ticker.C:
+            t.draw_status(b)
+            continue
+        }
+        b.message = ""
+        switch {
+        case key == 'q' || key == KEY_QUIT:
+            return
+        case key == KEY_UP || key == 'k':
+            b.move(-1, 0)
+        case key == KEY_DOWN || key == 'j':
+            b.move(1, 0)
+        case key == KEY_LEFT || key == 'h':
+            b.move(0, -1)
+        case key == KEY_RIGHT || key == 'l':
+            b.move(0, 1)
+        case '1' <= key && key <= '9':
+            b.enter(key - '0')
+        case key == '0' || key == '.' || key == ' ' || key == KEY_DELETE:
+            b.clear()
+        case key == 'p':
+            b.pencil = ! b.pencil
+        case key == '?':
+            b.hint()
+        case key == 'u':
+            b.undo()
+        case key == 'r':
+            b.redo()
+        }
+        t.draw(b)
+    }
+}
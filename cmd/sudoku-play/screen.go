@@ -0,0 +1,245 @@
+package main
+
+/* The terminal: raw mode with the help of stty, keys as they come in, and
+ * the drawing with ANSI escape sequences. No libraries beyond the standard
+ * library are needed, any VT100 compatible terminal will do.
+ *
+ * A goroutine reads the terminal and passes the bytes on to a channel, so
+ * the clock can be redrawn while no key is pressed. The bytes of an escape
+ * sequence follow each other at once; an escape with nothing after it
+ * within ESCAPE_WAIT is the escape key itself.
+ */
+
+import (
+  "bufio"
+  "fmt"
+  "os"
+  "os/exec"
+  "strings"
+  "time"
+)
+
+// keys besides the printable characters
+const (
+    KEY_UP = 1000 + iota
+    KEY_DOWN
+    KEY_LEFT
+    KEY_RIGHT
+    KEY_DELETE
+    KEY_ESCAPE
+    KEY_QUIT
+)
+
+// ANSI attributes
+const (
+    RESET    = "\x1b[0m"
+    BOLD     = "\x1b[1m"
+    DIM      = "\x1b[2m"
+    REVERSE  = "\x1b[7m"
+    BLUE     = "\x1b[34m"
+    RED_BACK = "\x1b[41m"
+)
+
+// how long to wait for the rest of an escape sequence
+const ESCAPE_WAIT = 50 * time.Millisecond
+
+type terminal struct {
+    tty   *os.File
+    in    *bufio.Reader
+    bytes chan byte // from the terminal, closed when reading fails
+    saved string    // stty settings to restore
+}
+
+func open_terminal() (*terminal, error) {
+    tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+    if err != nil {
+        return nil, err
+    }
+    t := &terminal{tty: tty, in: bufio.NewReader(tty),
+        bytes: make(chan byte, 16)}
+    saved, err := t.stty("-g")
+    if err != nil {
+        tty.Close()
+        return nil, err
+    }
+    t.saved = strings.TrimSpace(saved)
+    if _, err := t.stty("raw", "-echo"); err != nil {
+        tty.Close()
+        return nil, err
+    }
+    fmt.Fprint(tty, "\x1b[?25l") // hide the cursor
+    go t.read()
+    return t, nil
+}
+
+func (t *terminal) close() {
+    fmt.Fprint(t.tty, "\x1b[?25h\x1b[2J\x1b[H")
+    t.stty(t.saved)
+    t.tty.Close()
+}
+
+func (t *terminal) stty(args ...string) (string, error) {
+    cmd := exec.Command("stty", args...)
+    cmd.Stdin = t.tty
+    out, err := cmd.Output()
+    return string(out), err
+}
+
+func (t *terminal) read() {
+    // pass the bytes from the terminal on, until reading fails
+    for {
+        char, err := t.in.ReadByte()
+        if err != nil {
+            close(t.bytes)
+            return
+        }
+        t.bytes <- char
+    }
+}
+
+func (t *terminal) next() (byte, bool) {
+    // the next byte of an escape sequence, false if none follows
+    select {
+    case char, ok := <-t.bytes:
+        return char, ok
+    case <-time.After(ESCAPE_WAIT):
+        return 0, false
+    }
+}
+
+func (t *terminal) key(char byte, ok bool) int {
+    // the key starting with 'char', arrow keys and delete come as escape
+    // sequences
+    if ! ok {
+        return KEY_QUIT
+    }
+    switch char {
+    case 3, 4: // ctrl-c, ctrl-d
+        return KEY_QUIT
+    case 8, 127:
+        return KEY_DELETE
+    case 27:
+        next, ok := t.next()
+        switch {
+        case ! ok:
+            return KEY_ESCAPE
+        case next != '[':
+            return int(next)
+        }
+        code, _ := t.next()
+        switch code {
+        case 'A':
+            return KEY_UP
+        case 'B':
+            return KEY_DOWN
+        case 'C':
+            return KEY_RIGHT
+        case 'D':
+            return KEY_LEFT
+        case '3':
+            t.next() // '~'
+            return KEY_DELETE
+        }
+        return 0
+    }
+    return int(char)
+}
+
+/*==============================================================================
+ *  drawing
+ *==============================================================================
+ */
+func (t *terminal) draw(b *board) {
+    // every cell is 3 x 3 characters: a digit in the middle, or the pencil
+    // marks arranged like a phone keypad
+    var sb strings.Builder
+    sb.WriteString("\x1b[H\x1b[2J")
+    line := func(text string) {
+        sb.WriteString(text + "\r\n")
+    }
+
+    line(status(b))
+    line("")
+    border := "  +" + strings.Repeat(strings.Repeat("-", 12) + "-+", 3)
+    for r := 0; r < Nine; r++ {
+        if r % 3 == 0 {
+            line(border)
+        }
+        for sub := 0; sub < 3; sub++ {
+            row := "  |"
+            for c := 0; c < Nine; c++ {
+                row += " " + cell_text(b, r * 9 + c, sub)
+                if c % 3 == 2 {
+                    row += " |"
+                }
+            }
+            line(row)
+        }
+        if r % 3 != 2 {
+            line("  |" + strings.Repeat(strings.Repeat(" ", 13) + "|", 3))
+        }
+    }
+    line(border)
+    line("")
+
+    mode := "digits"
+    if b.pencil {
+        mode = "pencil marks"
+    }
+    line(fmt.Sprintf("  cell %s   mode: %s", cell_label(b.cursor), mode))
+    line("  " + b.message)
+    line("")
+    line("  arrows/hjkl move   1-9 enter   0/del clear   p pencil mode")
+    line("  ? hint   u undo   r redo   q quit")
+    fmt.Fprint(t.tty, sb.String())
+}
+
+func (t *terminal) draw_status(b *board) {
+    // just the first line, for the clock
+    fmt.Fprint(t.tty, "\x1b[H" + status(b) + "\x1b[K")
+}
+
+func status(b *board) string {
+    return fmt.Sprintf("  %s   %s   mistakes %d", b.session.Puzzle(),
+        b.session.Elapsed().Truncate(time.Second), b.session.Mistakes())
+}
+
+func cell_text(b *board, cell, sub int) string {
+    // line 'sub' of the 3 x 3 characters of 'cell'
+    style := ""
+    if b.session.Conflict(cell) {
+        style = RED_BACK
+    }
+    if cell == b.cursor {
+        style += REVERSE
+    }
+
+    text := "   "
+    if value := b.session.Value(cell); value != 0 {
+        if sub == 1 {
+            text = fmt.Sprintf(" %d ", value)
+        }
+        if b.session.Given(cell) {
+            style += BOLD
+        } else {
+            style += BLUE
+        }
+    } else if marks := b.session.Marks(cell); len(marks) > 0 {
+        line := [] byte("   ")
+        for _, d := range marks {
+            if (d - 1) / 3 == sub {
+                line[(d - 1) % 3] = byte('0' + d)
+            }
+        }
+        text = string(line)
+        style += DIM
+    }
+    if style == "" {
+        return text
+    }
+    return style + text + RESET
+}
+
+func cell_label(cell int) string {
+    return fmt.Sprintf("r%dc%d", cell / 9 + 1, cell % 9 + 1)
+}
@@ -0,0 +1,45 @@
+package main
+
+/* HTTP front end for the sudoku packages, for clients which would rather
+ * call a local service than link Go code.
+ *
+ *   sudoku-server [-addr :8080] [-timeout 2s] [-max-nodes 200000]
+ *
+ * All endpoints take a POST with a JSON body and answer with JSON, see
+ * server.go for the requests and responses. Puzzles may be given in any
+ * format sudoku_parser understands.
+ */
+
+import (
+  "flag"
+  "fmt"
+  "net/http"
+  "os"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+func main() {
+    var cfg config
+    addr := flag.String("addr", ":8080", "address to listen on")
+    flag.DurationVar(&cfg.timeout, "timeout", 2 * time.Second,
+        "time limit for one request")
+    flag.IntVar(&cfg.limits.Max_nodes, "max-nodes", 200000,
+        "search nodes for one request, 0 for no limit")
+    flag.Parse()
+
+    sudoku_solver.Setup_solver_once()
+    server := &http.Server{
+        Addr:         *addr,
+        Handler:      new_handler(cfg),
+        ReadTimeout:  10 * time.Second,
+        WriteTimeout: cfg.timeout + 10 * time.Second,
+    }
+    fmt.Fprintf(os.Stderr, "sudoku-server: listening on %s\n", *addr)
+    if err := server.ListenAndServe(); err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku-server: %v\n", err)
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,328 @@
+package main
+
+/* The handlers. Every request runs with a solver of its own, under the
+ * time limit and the node limit of the configuration, so a hostile puzzle
+ * cannot keep the server busy.
+ *
+ *   /solve     {"puzzle": p}          -> {"solution": s}
+ *   /count     {"puzzle": p,
+ *               "limit": n}           -> {"count": n, "complete": b}
+ *   /rate      {"puzzle": p}          -> {"level": l, "score": n,
+ *                                         "steps": n, "guesses": n}
+ *   /hint      {"puzzle": p}          -> {"technique": t, "digit": d,
+ *                                         "cell": "[12]", "group": g,
+ *                                         "eliminated": [...], "text": t,
+ *                                         "solved": false}
+ *                                         or {"solved": true, "text": t}
+ *                                         for a puzzle without empty cells
+ *   /generate  {"symmetric": b,
+ *               "seed": n}            -> {"puzzle": p, "solution": s}
+ *   /validate  {"puzzle": p}          -> {"valid": b, "count": n,
+ *                                         "reason": r}
+ *
+ * Puzzles and solutions in the responses are 81 character lines.
+ * Errors are answered with {"error": e} and
+ *   400  the request or the puzzle cannot be parsed
+ *   405  not a POST
+ *   422  no answer: no solution, no logical step, or a limit was hit
+ * The handler is an ordinary http.Handler, it can be tested with httptest.
+ */
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "math/rand"
+  "net/http"
+  "sync"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_format"
+  "github.com/wplapper/go-sudoku3/sudoku_generator"
+  "github.com/wplapper/go-sudoku3/sudoku_parser"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+// largest request body accepted
+const MAX_BODY = 64 * 1024
+
+type config struct {
+    timeout time.Duration // 0 for no limit
+    limits  sudoku_solver.Limits
+}
+
+type request struct {
+    Puzzle    string `json:"puzzle"`
+    Limit     int    `json:"limit"`
+    Symmetric bool   `json:"symmetric"`
+    Seed      *int64 `json:"seed"`
+}
+
+// sudoku_generator works with the default solver: one at a time
+var generate_lock sync.Mutex
+
+type handler_func func(ctx context.Context, req request) (int, any)
+
+func new_handler(cfg config) http.Handler {
+    mux := http.NewServeMux()
+    endpoints := map[string] func(cfg config) handler_func {
+        "/solve":    handle_solve,
+        "/count":    handle_count,
+        "/rate":     handle_rate,
+        "/hint":     handle_hint,
+        "/generate": handle_generate,
+        "/validate": handle_validate,
+    }
+    for path, endpoint := range endpoints {
+        mux.Handle(path, serve(cfg, endpoint(cfg)))
+    }
+    return mux
+}
+
+func serve(cfg config, handle handler_func) http.Handler {
+    // decode the request, run 'handle' within the time limit, encode
+    // the answer
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var req request
+        if r.Method != http.MethodPost {
+            w.Header().Set("Allow", http.MethodPost)
+            reply(w, http.StatusMethodNotAllowed, fail("POST only"))
+            return
+        }
+        decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_BODY))
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&req); err != nil {
+            reply(w, http.StatusBadRequest, fail("bad request: %v", err))
+            return
+        }
+
+        ctx := r.Context()
+        if cfg.timeout > 0 {
+            var cancel context.CancelFunc
+            ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+            defer cancel()
+        }
+        status, answer := handle(ctx, req)
+        reply(w, status, answer)
+    })
+}
+
+/*==============================================================================
+ *  endpoints
+ *==============================================================================
+ */
+func handle_solve(cfg config) handler_func {
+    return func(ctx context.Context, req request) (int, any) {
+        puzzle, err := sudoku_parser.Parse_one(req.Puzzle)
+        if err != nil {
+            return http.StatusBadRequest, fail("%v", err)
+        }
+        outcome := sudoku_solver.New_solver().Solve_context(ctx, puzzle,
+            cfg.limits)
+        if outcome.Count == 0 {
+            return http.StatusUnprocessableEntity, give_up(outcome)
+        }
+        return http.StatusOK, map[string] any {
+            "solution": sudoku_format.Line(outcome.Grid),
+        }
+    }
+}
+
+func handle_count(cfg config) handler_func {
+    return func(ctx context.Context, req request) (int, any) {
+        puzzle, err := sudoku_parser.Parse_one(req.Puzzle)
+        if err != nil {
+            return http.StatusBadRequest, fail("%v", err)
+        }
+        if req.Limit < 0 {
+            return http.StatusBadRequest, fail("negative limit")
+        }
+        outcome := sudoku_solver.New_solver().Count_context(ctx, puzzle,
+            req.Limit, cfg.limits)
+        // an incomplete count is still an answer: at least 'count'
+        return http.StatusOK, map[string] any {
+            "count":    outcome.Count,
+            "complete": outcome.Reason == sudoku_solver.COMPLETE,
+            "reason":   outcome.Reason,
+        }
+    }
+}
+
+func handle_rate(cfg config) handler_func {
+    return func(ctx context.Context, req request) (int, any) {
+        puzzle, err := sudoku_parser.Parse_one(req.Puzzle)
+        if err != nil {
+            return http.StatusBadRequest, fail("%v", err)
+        }
+        // the uniqueness check of Rate runs within the limits as well
+        rating, reason := sudoku_solver.New_solver().Rate_context(ctx, puzzle,
+            cfg.limits)
+        switch {
+        case reason != sudoku_solver.COMPLETE:
+            return http.StatusUnprocessableEntity, map[string] any {
+                "error":    "gave up: " + reason,
+                "complete": false,
+                "reason":   reason,
+            }
+        case rating.Level == "invalid":
+            return http.StatusUnprocessableEntity, fail("no solution")
+        case rating.Level == "multiple":
+            return http.StatusUnprocessableEntity,
+                fail("more than one solution")
+        }
+        return http.StatusOK, map[string] any {
+            "level":   rating.Level,
+            "score":   rating.Score,
+            "steps":   rating.Steps,
+            "guesses": rating.Guesses,
+        }
+    }
+}
+
+func handle_hint(cfg config) handler_func {
+    return func(ctx context.Context, req request) (int, any) {
+        puzzle, solver, status, answer := unique(ctx, cfg, req)
+        if solver == nil {
+            return status, answer
+        }
+        if solved(puzzle) {
+            return http.StatusOK, map[string] any {
+                "solved": true,
+                "text":   "the puzzle is solved already",
+            }
+        }
+        solver.Record = true
+        solver.Start_solver(puzzle)
+        if len(solver.Steps) == 0 {
+            return http.StatusUnprocessableEntity,
+                fail("no logical step, guessing is needed")
+        }
+
+        step := solver.Steps[0]
+        eliminated := sudoku_format.Cell_names(step.Eliminated)
+        if eliminated == nil {
+            // an array in any case, not null
+            eliminated = [] string {}
+        }
+        hint := map[string] any {
+            "technique":  step.Technique,
+            "digit":      step.Digit,
+            "eliminated": eliminated,
+            "text":       step.String(),
+            "solved":     false,
+        }
+        if step.Cell >= 0 {
+            hint["cell"] = sudoku_format.Cell_name(step.Cell)
+        }
+        if step.Group >= 0 {
+            hint["group"] = sudoku_solver.Group_name(step.Group)
+        }
+        return http.StatusOK, hint
+    }
+}
+
+func handle_generate(cfg config) handler_func {
+    return func(ctx context.Context, req request) (int, any) {
+        seed := time.Now().UnixNano()
+        if req.Seed != nil {
+            seed = *req.Seed
+        }
+
+        generate_lock.Lock()
+        defer generate_lock.Unlock()
+        if err := ctx.Err(); err != nil {
+            return http.StatusUnprocessableEntity, fail("%v", err)
+        }
+        puzzle, solution := sudoku_generator.Generate(
+            rand.New(rand.NewSource(seed)), req.Symmetric)
+        return http.StatusOK, map[string] any {
+            "puzzle":   puzzle,
+            "solution": sudoku_format.Line(solution),
+            "seed":     seed,
+        }
+    }
+}
+
+func handle_validate(cfg config) handler_func {
+    return func(ctx context.Context, req request) (int, any) {
+        puzzle, err := sudoku_parser.Parse_one(req.Puzzle)
+        if err != nil {
+            return http.StatusBadRequest, fail("%v", err)
+        }
+        outcome := sudoku_solver.New_solver().Count_context(ctx, puzzle, 2,
+            cfg.limits)
+        if outcome.Reason != sudoku_solver.COMPLETE && outcome.Count < 2 {
+            return http.StatusUnprocessableEntity, give_up(outcome)
+        }
+
+        reason := ""
+        switch outcome.Count {
+        case 0:
+            reason = "no solution"
+        case 2:
+            reason = "more than one solution"
+        }
+        return http.StatusOK, map[string] any {
+            "valid":  outcome.Count == 1,
+            "count":  outcome.Count,
+            "reason": reason,
+        }
+    }
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func unique(ctx context.Context, cfg config, req request) (string,
+    *sudoku_solver.Solver, int, any) {
+    // parse the puzzle and make sure it has a unique solution within the
+    // limits; on success a solver for further work is returned
+    puzzle, err := sudoku_parser.Parse_one(req.Puzzle)
+    if err != nil {
+        return "", nil, http.StatusBadRequest, fail("%v", err)
+    }
+    solver  := sudoku_solver.New_solver()
+    outcome := solver.Count_context(ctx, puzzle, 2, cfg.limits)
+    switch {
+    case outcome.Count == 2:
+        return "", nil, http.StatusUnprocessableEntity,
+            fail("more than one solution")
+    case outcome.Reason != sudoku_solver.COMPLETE || outcome.Count == 0:
+        return "", nil, http.StatusUnprocessableEntity, give_up(outcome)
+    }
+    return puzzle, solver, http.StatusOK, nil
+}
+
+func solved(puzzle string) bool {
+    // true if every cell of 'puzzle' holds a digit, and they are all fine
+    var grid [81] int
+    for cell := 0; cell < 81; cell++ {
+        if '1' <= puzzle[cell] && puzzle[cell] <= '9' {
+            grid[cell] = int(puzzle[cell] - '0')
+        }
+    }
+    return sudoku_solver.Verify(grid) == nil
+}
+
+func give_up(outcome sudoku_solver.Outcome) any {
+    // no solution, or the limits were hit before one was found
+    if outcome.Reason == sudoku_solver.COMPLETE {
+        return fail("no solution")
+    }
+    return map[string] any {
+        "error":   "gave up: " + outcome.Reason,
+        "partial": sudoku_format.Line(outcome.Grid),
+    }
+}
+
+func fail(format string, args ...any) any {
+    return map[string] string {"error": fmt.Sprintf(format, args...)}
+}
+
+func reply(w http.ResponseWriter, status int, answer any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(answer)
+}
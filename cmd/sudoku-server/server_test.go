@@ -0,0 +1,160 @@
+package main
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "strings"
+  "testing"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+// an easy puzzle with a unique solution, the solver functions finish it
+const EASY = "..3.2.6..9..3.5..1..18.64....81.29..7.......8..67.82....26.95..8..2.3..9..5.1.3.."
+const EASY_SOLUTION = "483921657967345821251876493548132976729564138136798245372689514814253769695417382"
+
+var EMPTY   = strings.Repeat(".", 81)
+var BROKEN  = "11" + strings.Repeat(".", 79)
+var BROKEN_SOLUTION = EASY_SOLUTION[1:] + EASY_SOLUTION[:1] // rows shifted
+
+func TestMain(m *testing.M) {
+    sudoku_solver.Setup_solver_once()
+    os.Exit(m.Run())
+}
+
+type server_case struct {
+    name   string
+    method string
+    path   string
+    body   string
+    status int
+    want   map[string] any // fields the answer must have, nil: any value
+}
+
+func TestEndpoints(t *testing.T) {
+    cases := [] server_case {
+        {"solve", "POST", "/solve", `{"puzzle": "` + EASY + `"}`, 200,
+            map[string] any {"solution": EASY_SOLUTION}},
+        {"solve get", "GET", "/solve", "", 405, map[string] any {"error": nil}},
+        {"solve malformed", "POST", "/solve", `{"puzzle": "123"}`, 400,
+            map[string] any {"error": nil}},
+        {"solve bad json", "POST", "/solve", `{"puzzle": `, 400,
+            map[string] any {"error": nil}},
+        {"solve unknown field", "POST", "/solve", `{"grid": "1"}`, 400,
+            map[string] any {"error": nil}},
+        {"solve unsolvable", "POST", "/solve", `{"puzzle": "` + BROKEN + `"}`,
+            422, map[string] any {"error": "no solution"}},
+
+        {"count", "POST", "/count", `{"puzzle": "` + EASY + `"}`, 200,
+            map[string] any {"count": 1.0, "complete": true}},
+        {"count limit", "POST", "/count",
+            `{"puzzle": "` + EMPTY + `", "limit": 3}`, 200,
+            map[string] any {"count": 3.0, "complete": true}},
+        {"count get", "GET", "/count", "", 405, nil},
+        {"count malformed", "POST", "/count", `{"puzzle": "x"}`, 400, nil},
+        {"count negative", "POST", "/count",
+            `{"puzzle": "` + EASY + `", "limit": -1}`, 400, nil},
+
+        {"rate", "POST", "/rate", `{"puzzle": "` + EASY + `"}`, 200,
+            map[string] any {"level": nil, "score": nil, "guesses": 0.0}},
+        {"rate get", "GET", "/rate", "", 405, nil},
+        {"rate malformed", "POST", "/rate", `{"puzzle": "x"}`, 400, nil},
+        {"rate unsolvable", "POST", "/rate", `{"puzzle": "` + BROKEN + `"}`,
+            422, map[string] any {"error": "no solution"}},
+        {"rate multiple", "POST", "/rate", `{"puzzle": "` + EMPTY + `"}`, 422,
+            map[string] any {"error": "more than one solution"}},
+
+        {"hint", "POST", "/hint", `{"puzzle": "` + EASY + `"}`, 200,
+            map[string] any {"technique": nil, "digit": nil, "cell": nil,
+                "eliminated": [] any {}, "text": nil, "solved": false}},
+        {"hint solved", "POST", "/hint", `{"puzzle": "` + EASY_SOLUTION + `"}`,
+            200, map[string] any {"solved": true, "text": nil}},
+        {"hint solved wrong", "POST", "/hint",
+            `{"puzzle": "` + BROKEN_SOLUTION + `"}`, 422,
+            map[string] any {"error": "no solution"}},
+        {"hint get", "GET", "/hint", "", 405, nil},
+        {"hint malformed", "POST", "/hint", `{"puzzle": "x"}`, 400, nil},
+        {"hint multiple", "POST", "/hint", `{"puzzle": "` + EMPTY + `"}`, 422,
+            map[string] any {"error": "more than one solution"}},
+        {"hint unsolvable", "POST", "/hint", `{"puzzle": "` + BROKEN + `"}`,
+            422, nil},
+
+        {"generate", "POST", "/generate", `{"seed": 7, "symmetric": true}`,
+            200, map[string] any {"puzzle": nil, "solution": nil,
+                "seed": 7.0}},
+        {"generate get", "GET", "/generate", "", 405, nil},
+        {"generate bad json", "POST", "/generate", `{"seed": "x"}`, 400, nil},
+
+        {"validate", "POST", "/validate", `{"puzzle": "` + EASY + `"}`, 200,
+            map[string] any {"valid": true, "count": 1.0}},
+        {"validate multiple", "POST", "/validate",
+            `{"puzzle": "` + EMPTY + `"}`, 200,
+            map[string] any {"valid": false,
+                "reason": "more than one solution"}},
+        {"validate unsolvable", "POST", "/validate",
+            `{"puzzle": "` + BROKEN + `"}`, 200,
+            map[string] any {"valid": false, "reason": "no solution"}},
+        {"validate get", "GET", "/validate", "", 405, nil},
+        {"validate malformed", "POST", "/validate", `{"puzzle": "x"}`, 400,
+            nil},
+    }
+    handler := new_handler(config{timeout: 10 * time.Second,
+        limits: sudoku_solver.Limits{Max_nodes: 200000}})
+    run_cases(t, handler, cases)
+}
+
+func TestBudgetExhausted(t *testing.T) {
+    // the empty grid needs a search, one node is not enough for anything
+    cases := [] server_case {
+        {"solve", "POST", "/solve", `{"puzzle": "` + EMPTY + `"}`, 422,
+            map[string] any {"error": "gave up: node limit", "partial": nil}},
+        {"count", "POST", "/count", `{"puzzle": "` + EMPTY + `"}`, 200,
+            map[string] any {"complete": false, "reason": "node limit"}},
+        {"rate", "POST", "/rate", `{"puzzle": "` + EMPTY + `"}`, 422,
+            map[string] any {"complete": false, "reason": "node limit"}},
+        {"hint", "POST", "/hint", `{"puzzle": "` + EMPTY + `"}`, 422,
+            map[string] any {"error": "gave up: node limit"}},
+        {"validate", "POST", "/validate", `{"puzzle": "` + EMPTY + `"}`, 422,
+            map[string] any {"error": "gave up: node limit"}},
+    }
+    handler := new_handler(config{limits: sudoku_solver.Limits{Max_nodes: 1}})
+    run_cases(t, handler, cases)
+}
+
+func run_cases(t *testing.T, handler http.Handler, cases [] server_case) {
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            var answer map[string] any
+            r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+            w := httptest.NewRecorder()
+            handler.ServeHTTP(w, r)
+
+            if w.Code != c.status {
+                t.Fatalf("status %d, want %d: %s", w.Code, c.status,
+                    w.Body.String())
+            }
+            if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil {
+                t.Fatalf("answer is no JSON object: %v", err)
+            }
+            for key, want := range c.want {
+                got, ok := answer[key]
+                switch {
+                case ! ok:
+                    t.Errorf("%q missing in %v", key, answer)
+                case want != nil && ! same(got, want):
+                    t.Errorf("%q is %v, want %v", key, got, want)
+                }
+            }
+        })
+    }
+}
+
+func same(got, want any) bool {
+    a, _ := json.Marshal(got)
+    b, _ := json.Marshal(want)
+    return string(a) == string(b)
+}
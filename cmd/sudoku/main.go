@@ -0,0 +1,547 @@
+package main
+
+/* Command line front end for the sudoku packages.
+ *
+ *   sudoku <command> [flags] [file ...]
+ *
+ * The puzzles are read from the files given, or from stdin, in any format
+ * sudoku_parser understands. Results go to stdout, one per puzzle.
+ *
+ * Exit codes:
+ *   0  everything fine
+ *   1  at least one puzzle has no solution (solve) or is not a proper
+ *      puzzle with a unique solution (rate, batch)
+ *   2  usage error, unreadable file or unparsable input
+ */
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "math/rand"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_batch"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_dots"
+  "github.com/wplapper/go-sudoku3/sudoku_format"
+  "github.com/wplapper/go-sudoku3/sudoku_generator"
+  "github.com/wplapper/go-sudoku3/sudoku_killer"
+  "github.com/wplapper/go-sudoku3/sudoku_lines"
+  "github.com/wplapper/go-sudoku3/sudoku_parser"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+  "github.com/wplapper/go-sudoku3/sudoku_symmetry"
+)
+
+const (
+    EXIT_OK     = 0
+    EXIT_FAILED = 1
+    EXIT_USAGE  = 2
+
+    // internal: the flags asked for help, stop and exit with EXIT_OK
+    HELP = -1
+)
+
+type command struct {
+    name string
+    help string
+    run  func(args [] string) int
+}
+
+var commands [] command
+
+func init() {
+    commands = [] command {
+        {"solve",    "print the solution of every puzzle", cmd_solve},
+        {"count",    "print the number of solutions of every puzzle",
+            cmd_count},
+        {"rate",     "print the difficulty rating of every puzzle", cmd_rate},
+        {"generate", "print new puzzles with a unique solution",
+            cmd_generate},
+        {"canon",    "print the canonical form of every puzzle", cmd_canon},
+        {"format",   "print every puzzle in another format", cmd_format},
+        {"batch",    "solve large puzzle files and print statistics",
+            cmd_batch},
+    }
+}
+
+func main() {
+    if len(os.Args) < 2 {
+        usage()
+        os.Exit(EXIT_USAGE)
+    }
+
+    sudoku_solver.Setup_solver_once()
+    for _, cmd := range commands {
+        if cmd.name == os.Args[1] {
+            code := cmd.run(os.Args[2:])
+            if code == HELP {
+                code = EXIT_OK
+            }
+            os.Exit(code)
+        }
+    }
+    fmt.Fprintf(os.Stderr, "sudoku: unknown command %q\n", os.Args[1])
+    usage()
+    os.Exit(EXIT_USAGE)
+}
+
+func usage() {
+    fmt.Fprintf(os.Stderr, "usage: sudoku <command> [flags] [file ...]\n\n")
+    fmt.Fprintf(os.Stderr, "commands:\n")
+    for _, cmd := range commands {
+        fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.help)
+    }
+    fmt.Fprintf(os.Stderr, "\nrun 'sudoku <command> -h' for the flags\n")
+}
+
+/*==============================================================================
+ *  commands
+ *==============================================================================
+ */
+func cmd_solve(args [] string) int {
+    flags   := flag.NewFlagSet("solve", flag.ContinueOnError)
+    backend := flags.String("backend", "bitmask", "solver: bitmask or dlx")
+    output  := flags.String("o", "line", "output: line, boxed or unicode")
+    variant := flags.String("variant", "classic", VARIANT_HELP)
+    rules   := flags.String("constraints", "", CONSTRAINTS_HELP)
+    puzzles, code := read_puzzles(flags, args)
+    if code != EXIT_OK {
+        return code
+    }
+    if ! set_backend(*backend) || ! check_output(*output, false) {
+        return EXIT_USAGE
+    }
+    solver := new_solver(*variant, *rules)
+    if solver == nil {
+        return EXIT_USAGE
+    }
+
+    for _, puzzle := range puzzles {
+        solution, ok := solver.Solve_puzzle(puzzle)
+        if ! ok {
+            fmt.Println("no solution")
+            code = EXIT_FAILED
+            continue
+        }
+        fmt.Print(render(*output, solution, nil))
+    }
+    return code
+}
+
+func cmd_count(args [] string) int {
+    flags   := flag.NewFlagSet("count", flag.ContinueOnError)
+    backend := flags.String("backend", "bitmask", "solver: bitmask or dlx")
+    limit   := flags.Int("limit", 1000, "stop counting at this number, " +
+        "0 for no limit")
+    variant := flags.String("variant", "classic", VARIANT_HELP)
+    rules   := flags.String("constraints", "", CONSTRAINTS_HELP)
+    puzzles, code := read_puzzles(flags, args)
+    if code != EXIT_OK {
+        return code
+    }
+    if ! set_backend(*backend) {
+        return EXIT_USAGE
+    }
+    solver := new_solver(*variant, *rules)
+    if solver == nil {
+        return EXIT_USAGE
+    }
+
+    for _, puzzle := range puzzles {
+        count := solver.Count_solutions(puzzle, *limit)
+        if *limit > 0 && count >= *limit {
+            fmt.Printf("%s %d+\n", puzzle, count)
+        } else {
+            fmt.Printf("%s %d\n", puzzle, count)
+        }
+    }
+    return code
+}
+
+func cmd_rate(args [] string) int {
+    flags   := flag.NewFlagSet("rate", flag.ContinueOnError)
+    variant := flags.String("variant", "classic", VARIANT_HELP)
+    rules   := flags.String("constraints", "", CONSTRAINTS_HELP)
+    puzzles, code := read_puzzles(flags, args)
+    if code != EXIT_OK {
+        return code
+    }
+    solver := new_solver(*variant, *rules)
+    if solver == nil {
+        return EXIT_USAGE
+    }
+
+    for _, puzzle := range puzzles {
+        rating := solver.Rate(puzzle)
+        fmt.Printf("%s %-8s score=%d steps=%d guesses=%d\n", puzzle,
+            rating.Level, rating.Score, rating.Steps, rating.Guesses)
+        if rating.Level == "invalid" || rating.Level == "multiple" {
+            code = EXIT_FAILED
+        }
+    }
+    return code
+}
+
+func cmd_generate(args [] string) int {
+    flags     := flag.NewFlagSet("generate", flag.ContinueOnError)
+    number    := flags.Int("n", 1, "number of puzzles")
+    seed      := flags.Int64("seed", 0, "random seed, 0 for the current time")
+    symmetric := flags.Bool("symmetric", false,
+        "givens symmetric under a rotation by 180 degrees")
+    if code := parse_flags(flags, args); code != EXIT_OK {
+        return code
+    }
+    if flags.NArg() > 0 {
+        flags.Usage()
+        return EXIT_USAGE
+    }
+
+    if *seed == 0 {
+        *seed = time.Now().UnixNano()
+    }
+    rng := rand.New(rand.NewSource(*seed))
+    for i := 0; i < *number; i++ {
+        puzzle, _ := sudoku_generator.Generate(rng, *symmetric)
+        fmt.Println(puzzle)
+    }
+    return EXIT_OK
+}
+
+func cmd_canon(args [] string) int {
+    flags := flag.NewFlagSet("canon", flag.ContinueOnError)
+    puzzles, code := read_puzzles(flags, args)
+    if code != EXIT_OK {
+        return code
+    }
+
+    for _, puzzle := range puzzles {
+        canonical, _ := sudoku_symmetry.Canonical(puzzle)
+        fmt.Println(canonical)
+    }
+    return code
+}
+
+func cmd_format(args [] string) int {
+    flags  := flag.NewFlagSet("format", flag.ContinueOnError)
+    output := flags.String("o", "boxed",
+        "output: line, boxed, unicode or pencil")
+    puzzles, code := read_puzzles(flags, args)
+    if code != EXIT_OK {
+        return code
+    }
+    if ! check_output(*output, true) {
+        return EXIT_USAGE
+    }
+
+    for _, puzzle := range puzzles {
+        // the candidates left after placing the givens
+        sudoku_solver.Load_puzzle(puzzle)
+        locations := sudoku_solver.Locations()
+        fmt.Print(render(*output, sudoku_solver.Contents(), &locations))
+    }
+    return code
+}
+
+func cmd_batch(args [] string) int {
+    // stream the puzzles instead of reading them all first
+    var summary sudoku_batch.Summary
+    flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+    quiet := flags.Bool("q", false, "print the summary only")
+    jobs  := flags.Int("j", 0, "number of workers, 0 for one per CPU")
+    if code := parse_flags(flags, args); code != EXIT_OK {
+        return code
+    }
+
+    report := func(result sudoku_batch.Result) {
+        if *quiet {
+            return
+        }
+        solution := "-"
+        if result.Status == sudoku_batch.LOGIC ||
+            result.Status == sudoku_batch.SEARCH {
+            solution = sudoku_format.Line(result.Solution)
+        }
+        fmt.Printf("%d %s %-8s %s %dus\n", result.Index, result.Puzzle,
+            result.Status, solution, result.Duration.Microseconds())
+    }
+
+    paths := flags.Args()
+    if len(paths) == 0 {
+        paths = [] string {"-"}
+    }
+    for _, path := range paths {
+        part, ok := batch_file(path, *jobs, report)
+        summary.Merge(part)
+        if ! ok {
+            return EXIT_USAGE
+        }
+    }
+
+    fmt.Print(summary)
+    if summary.Invalid > 0 || summary.Multiple > 0 {
+        return EXIT_FAILED
+    }
+    return EXIT_OK
+}
+
+func batch_file(path string, jobs int,
+    report func(sudoku_batch.Result)) (sudoku_batch.Summary, bool) {
+    // run the batch for one file ("-" for stdin), closing it when done
+    var file io.Reader = os.Stdin
+    if path != "-" {
+        f, err := os.Open(path)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "sudoku: %v\n", err)
+            return sudoku_batch.Summary{}, false
+        }
+        defer f.Close()
+        file = f
+    }
+    summary, err := sudoku_batch.Run_parallel(file, jobs, report)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku: %s: %v\n", path, err)
+        return summary, false
+    }
+    return summary, true
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func read_puzzles(flags *flag.FlagSet, args [] string) ([] string, int) {
+    // parse the flags, then read all puzzles from the remaining arguments
+    // or from stdin
+    var puzzles [] string
+    if code := parse_flags(flags, args); code != EXIT_OK {
+        return nil, code
+    }
+
+    if flags.NArg() == 0 {
+        return read_from("stdin", os.Stdin)
+    }
+    for _, path := range flags.Args() {
+        file, err := os.Open(path)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "sudoku: %v\n", err)
+            return nil, EXIT_USAGE
+        }
+        more, code := read_from(path, file)
+        file.Close()
+        if code != EXIT_OK {
+            return nil, code
+        }
+        puzzles = append(puzzles, more...)
+    }
+    return puzzles, EXIT_OK
+}
+
+func parse_flags(flags *flag.FlagSet, args [] string) int {
+    // asking for help is not an error
+    err := flags.Parse(args)
+    if err == flag.ErrHelp {
+        return HELP
+    } else if err != nil {
+        return EXIT_USAGE
+    }
+    return EXIT_OK
+}
+
+func read_from(name string, r io.Reader) ([] string, int) {
+    puzzles, err := sudoku_parser.Parse_reader(r)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku: %s: %v\n", name, err)
+        return nil, EXIT_USAGE
+    }
+    return puzzles, EXIT_OK
+}
+
+func set_backend(name string) bool {
+    switch name {
+    case "bitmask":
+        sudoku_solver.Backend = sudoku_solver.BITMASK
+    case "dlx":
+        sudoku_solver.Backend = sudoku_solver.DLX
+    default:
+        fmt.Fprintf(os.Stderr, "sudoku: unknown backend %q\n", name)
+        return false
+    }
+    return true
+}
+
+const VARIANT_HELP = "sudoku variant: classic, diagonal, windoku or " +
+    "jigsaw:<81 character region map>, optionally followed by +anti-knight " +
+    "and/or +anti-king"
+
+const CONSTRAINTS_HELP = "file with more constraints, one per line: " +
+    "'cage <sum> <cell> ...' for a killer cage, 'thermo <bulb> <cell> ...', " +
+    "'arrow <circle> <cell> ...', 'palindrome <cell> ...', " +
+    "'white|black|x|v <cell> <cell>' for a dot, 'greater <cell> <cell>' " +
+    "and 'negative kropki|xv' if all those dots are given; " +
+    "cells are written r1c1"
+
+func new_solver(name string, rules string) *sudoku_solver.Solver {
+    // a solver for the variant 'name' and the constraints in the file
+    // 'rules', after the backend has been set
+    var variant *sudoku_constants.Variant
+    parts := strings.Split(name, "+")
+    switch parts[0] {
+    case "classic":
+        variant = sudoku_constants.Classic
+    case "diagonal":
+        variant = sudoku_constants.Diagonal()
+    case "windoku":
+        variant = sudoku_constants.Windoku()
+    default:
+        regions, ok := strings.CutPrefix(parts[0], "jigsaw:")
+        if ! ok {
+            fmt.Fprintf(os.Stderr, "sudoku: unknown variant %q\n", parts[0])
+            return nil
+        }
+        jigsaw, err := sudoku_constants.Jigsaw(regions)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "sudoku: %v\n", err)
+            return nil
+        }
+        variant = jigsaw
+    }
+
+    for _, extra := range parts[1:] {
+        switch extra {
+        case "anti-knight":
+            variant = variant.Anti_knight()
+        case "anti-king":
+            variant = variant.Anti_king()
+        default:
+            fmt.Fprintf(os.Stderr, "sudoku: unknown constraint %q\n", extra)
+            return nil
+        }
+    }
+    if rules == "" {
+        return sudoku_solver.New_variant_solver(variant)
+    }
+    return load_constraints(variant, rules)
+}
+
+func load_constraints(variant *sudoku_constants.Variant,
+    path string) *sudoku_solver.Solver {
+    // read the constraints file 'path', empty lines and lines starting with
+    // '#' are skipped
+    var cages [] sudoku_killer.Cage
+    var lines [] sudoku_solver.Constraint
+    var dots [] sudoku_dots.Dot
+    var negative [] string
+    data, err := os.ReadFile(path)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku: %v\n", err)
+        return nil
+    }
+
+    for n, line := range strings.Split(string(data), "\n") {
+        fields := strings.Fields(line)
+        if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+            continue
+        }
+        switch fields[0] {
+        case "cage":
+            var cage sudoku_killer.Cage
+            if len(fields) < 3 {
+                err = fmt.Errorf("a cage needs a sum and cells")
+            } else if cage.Sum, err = strconv.Atoi(fields[1]); err == nil {
+                cage.Cells, err = parse_cells(fields[2:])
+            }
+            cages = append(cages, cage)
+        case "thermo", "arrow", "palindrome":
+            var cells [] int
+            var line sudoku_solver.Constraint
+            if cells, err = parse_cells(fields[1:]); err == nil {
+                line, err = sudoku_lines.New_line(fields[0], cells)
+            }
+            lines = append(lines, line)
+        case "white", "black", "x", "v", "greater":
+            var cells [] int
+            if len(fields) != 3 {
+                err = fmt.Errorf("%s needs 2 cells", fields[0])
+            } else if cells, err = parse_cells(fields[1:]); err == nil {
+                dots = append(dots, sudoku_dots.Dot{Kind: fields[0],
+                    A: cells[0], B: cells[1]})
+            }
+        case "negative":
+            if len(fields) != 2 {
+                err = fmt.Errorf("negative needs kropki or xv")
+            }
+            negative = append(negative, fields[1:]...)
+        default:
+            err = fmt.Errorf("unknown constraint %q", fields[0])
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "sudoku: %s:%d: %v\n", path, n + 1, err)
+            return nil
+        }
+    }
+
+    solver := sudoku_solver.New_variant_solver(variant)
+    if len(cages) > 0 {
+        solver, err = sudoku_killer.New_solver(variant, cages)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "sudoku: %s: %v\n", path, err)
+            return nil
+        }
+    }
+    solver.Add_constraint(lines...)
+
+    pairs, err := sudoku_dots.Dots(dots, negative)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "sudoku: %s: %v\n", path, err)
+        return nil
+    }
+    solver.Add_constraint(pairs...)
+    return solver
+}
+
+func parse_cells(names [] string) ([] int, error) {
+    // cells named r<row>c<column>, rows and columns 1..9
+    cells := make([] int, len(names))
+    for i, name := range names {
+        lower := strings.ToLower(name)
+        if len(lower) != 4 || lower[0] != 'r' || lower[2] != 'c' ||
+            lower[1] < '1' || lower[1] > '9' || lower[3] < '1' ||
+            lower[3] > '9' {
+            return nil, fmt.Errorf("bad cell %q, use r1c1 .. r9c9", name)
+        }
+        cells[i] = int(lower[1] - '1') * 9 + int(lower[3] - '1')
+    }
+    return cells, nil
+}
+
+func check_output(name string, pencil bool) bool {
+    switch name {
+    case "line", "boxed", "unicode":
+        return true
+    case "pencil":
+        if pencil {
+            return true
+        }
+    }
+    fmt.Fprintf(os.Stderr, "sudoku: unknown output format %q\n", name)
+    return false
+}
+
+func render(output string, grid [81] int,
+    locations *[10] uint128.Uint128) string {
+    switch output {
+    case "boxed":
+        return sudoku_format.Boxed(grid)
+    case "unicode":
+        return sudoku_format.Unicode(grid)
+    case "pencil":
+        return sudoku_format.Pencil_marks(grid, *locations)
+    }
+    return sudoku_format.Line(grid) + "\n"
+}
@@ -0,0 +1,3 @@
+module github.com/wplapper/go-sudoku3
+
+go 1.21
@@ -0,0 +1,260 @@
+package sudoku_batch
+
+/* Solve large collections of puzzles and collect statistics.
+ *
+ * The puzzles are streamed with sudoku_parser.Scanner (comment and blank
+ * lines are skipped), every puzzle is classified as
+ *     logic     solved by the solver functions alone
+ *     search    unique solution, but guessing was needed
+ *     invalid   no solution
+ *     multiple  more than one solution
+ * and the summary counts the classes and measures the throughput.
+ *
+ * Run_parallel and Stream fan the puzzles out over several workers, each
+ * with a sudoku_solver.Solver of its own. The results come out in input
+ * order. At most WINDOW puzzles per worker are between reading and
+ * reporting, so a slow consumer slows down the reading of the input
+ * instead of piling up results.
+ *
+ * sudoku_solver.Setup_solver_once must have been called.
+ */
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "runtime"
+  "sync"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_parser"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const (
+    LOGIC    = "logic"
+    SEARCH   = "search"
+    INVALID  = "invalid"
+    MULTIPLE = "multiple"
+)
+
+// puzzles in flight per worker
+const WINDOW = 4
+
+type Result struct {
+    Index    int    // 1 for the first puzzle
+    Line     int    // line number in the input
+    Puzzle   string
+    Status   string // LOGIC, SEARCH, INVALID or MULTIPLE
+    Solution [81] int
+    Duration time.Duration
+}
+
+type Summary struct {
+    Total, Logic, Search, Invalid, Multiple int
+    Elapsed time.Duration
+}
+
+func Solve_one(puzzle string) Result {
+    // classify and solve a single puzzle
+    return Solve_with(sudoku_solver.New_solver(), puzzle)
+}
+
+func Solve_with(solver *sudoku_solver.Solver, puzzle string) Result {
+    // like Solve_one, reusing 'solver'
+    result := Result{Puzzle: puzzle}
+    start := time.Now()
+
+    if solver.Start_solver(puzzle) == 81 && ! solver.Broken() {
+        // the solver functions only take forced steps: the solution is unique
+        result.Status   = LOGIC
+        result.Solution = solver.Contents()
+    } else {
+        // one search: the count keeps the first solution it finds
+        outcome := solver.Count_context(context.Background(), puzzle, 2,
+            sudoku_solver.Limits{})
+        switch outcome.Count {
+        case 0:
+            result.Status = INVALID
+        case 1:
+            result.Status   = SEARCH
+            result.Solution = outcome.Grid
+        default:
+            result.Status = MULTIPLE
+        }
+    }
+    result.Duration = time.Since(start)
+    return result
+}
+
+func Run(r io.Reader, report func(Result)) (Summary, error) {
+    // solve all puzzles in 'r', call 'report' (if not nil) for each one
+    var summary Summary
+    start  := time.Now()
+    solver := sudoku_solver.New_solver()
+
+    scanner := sudoku_parser.New_scanner(r)
+    for scanner.Scan() {
+        result := Solve_with(solver, scanner.Puzzle())
+        result.Line = scanner.Line()
+        summary.Add(result)
+        result.Index = summary.Total
+        if report != nil {
+            report(result)
+        }
+    }
+    summary.Elapsed = time.Since(start)
+    return summary, scanner.Err()
+}
+
+func Run_parallel(r io.Reader, workers int, report func(Result)) (Summary,
+    error) {
+    // like Run, with 'workers' goroutines (0 for GOMAXPROCS). 'report' is
+    // called in input order from the calling goroutine.
+    var summary Summary
+    start := time.Now()
+
+    scanner := sudoku_parser.New_scanner(r)
+    jobs := make(chan Result)
+    go func() {
+        defer close(jobs)
+        for index := 1; scanner.Scan(); index++ {
+            jobs <- Result{Index: index, Line: scanner.Line(),
+                Puzzle: scanner.Puzzle()}
+        }
+    }()
+
+    for result := range parallel(jobs, workers) {
+        summary.Add(result)
+        if report != nil {
+            report(result)
+        }
+    }
+    summary.Elapsed = time.Since(start)
+    return summary, scanner.Err()
+}
+
+func Stream(puzzles <-chan string, workers int) <-chan Result {
+    // solve the puzzles from a channel with 'workers' goroutines (0 for
+    // GOMAXPROCS). The results come in input order, the channel is closed
+    // after the last one. Reading stops while results are not taken.
+    jobs := make(chan Result)
+    go func() {
+        defer close(jobs)
+        index := 0
+        for puzzle := range puzzles {
+            index++
+            jobs <- Result{Index: index, Puzzle: puzzle}
+        }
+    }()
+    return parallel(jobs, workers)
+}
+
+func (summary *Summary) Add(result Result) {
+    summary.Total++
+    switch result.Status {
+    case LOGIC:
+        summary.Logic++
+    case SEARCH:
+        summary.Search++
+    case INVALID:
+        summary.Invalid++
+    case MULTIPLE:
+        summary.Multiple++
+    }
+}
+
+func (summary *Summary) Merge(other Summary) {
+    // add the counts of 'other', e.g. for several input files
+    summary.Total    += other.Total
+    summary.Logic    += other.Logic
+    summary.Search   += other.Search
+    summary.Invalid  += other.Invalid
+    summary.Multiple += other.Multiple
+    summary.Elapsed  += other.Elapsed
+}
+
+func (summary Summary) Per_second() float64 {
+    if summary.Elapsed <= 0 {
+        return 0
+    }
+    return float64(summary.Total) / summary.Elapsed.Seconds()
+}
+
+func (summary Summary) String() string {
+    return fmt.Sprintf("total     %8d\n" +
+        "logic     %8d\n" +
+        "search    %8d\n" +
+        "invalid   %8d\n" +
+        "multiple  %8d\n" +
+        "elapsed   %8.3fs\n" +
+        "puzzles/s %8.0f\n",
+        summary.Total, summary.Logic, summary.Search, summary.Invalid,
+        summary.Multiple, summary.Elapsed.Seconds(), summary.Per_second())
+}
+
+/*==============================================================================
+ *  the worker pool
+ *==============================================================================
+ */
+func parallel(jobs <-chan Result, workers int) <-chan Result {
+    // solve the jobs, numbered 1, 2, 3 ... in 'Index', and deliver them in
+    // that order
+    if workers <= 0 {
+        workers = runtime.GOMAXPROCS(0)
+    }
+    window  := make(chan bool, workers * WINDOW)
+    queued  := make(chan Result)
+    done    := make(chan Result, workers)
+    results := make(chan Result)
+
+    // a job takes a place in the window before it is queued, and gives it
+    // back when its result is delivered
+    go func() {
+        defer close(queued)
+        for job := range jobs {
+            window <- true
+            queued <- job
+        }
+    }()
+
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            solver := sudoku_solver.New_solver()
+            for job := range queued {
+                result := Solve_with(solver, job.Puzzle)
+                result.Index, result.Line = job.Index, job.Line
+                done <- result
+            }
+        }()
+    }
+    go func() {
+        wg.Wait()
+        close(done)
+    }()
+
+    // restore the input order
+    go func() {
+        defer close(results)
+        pending := make(map[int] Result)
+        next := 1
+        for result := range done {
+            pending[result.Index] = result
+            for {
+                ready, ok := pending[next]
+                if ! ok {
+                    break
+                }
+                delete(pending, next)
+                results <- ready
+                <-window
+                next++
+            }
+        }
+    }()
+    return results
+}
@@ -5,9 +5,15 @@ package sudoku_constants
  * https://www.ics.uci.edu/~eppstein/PADS/Sudoku.py
  *
  * The uint128 implementation from https://github.com/lukechampine/uint128
+ *
+ * Variants: the package-level tables describe classic sudoku. A Variant has
+ * its own groups (sets of 9 cells holding every digit once), and a cell may
+ * belong to any number of them, so 'Unit_index' has 3 or more entries per
+ * cell. 'Classic' is the Variant made of the package-level tables.
  */
 
 import (
+  "fmt"
   "sort"
 
   // local
@@ -38,6 +44,22 @@ var Alignments_bysqua[][] Align //  9 * 24
 var Alignments_byline[][] Align // 18 * 12
 var Unit_index [][] int
 
+// the most groups a variant may have
+const MAX_GROUPS = 64
+
+type Variant struct {
+    Name        string
+    Group_masks [] uint128.Uint128
+    Group_names [] string
+    Unit_index  [][] int           // the groups of every cell
+    Neighbours  [] uint128.Uint128 // the cells which see a cell
+    // for every group the other groups sharing at least 2 cells with it,
+    // the candidates for an alignment
+    Crossings   [][] int
+}
+
+var Classic *Variant
+
 // basic building blocks
 func setup_powers() {
     // prepare all 2**i
@@ -208,4 +230,240 @@ func Setup_sudoku_constants() {
     setup_neighbours()
     setup_alignments_2dim()
     setup_alignments()
+    setup_classic()
+}
+
+/*==============================================================================
+ *  variants
+ *==============================================================================
+ */
+func setup_classic() {
+    names := make([] string, 27)
+    for i := 0; i < Nine; i++ {
+        names[i]            = fmt.Sprintf("box %d", i + 1)
+        names[i + Nine]     = fmt.Sprintf("row %d", i + 1)
+        names[i + Nine * 2] = fmt.Sprintf("column %d", i + 1)
+    }
+    Classic = New_variant("classic", Group_masks, names)
+}
+
+func New_variant(name string, masks [] uint128.Uint128,
+    names [] string) *Variant {
+    // a variant with the groups in 'masks'; the tables of a cell are derived
+    // from the groups it belongs to
+    if len(masks) > MAX_GROUPS || len(names) != len(masks) {
+        panic("bad number of groups or group names")
+    }
+    v := &Variant{Name: name}
+    v.Group_masks = append([] uint128.Uint128(nil), masks...)
+    v.Group_names = append([] string(nil), names...)
+
+    v.Unit_index = make([][] int, 81)
+    v.Neighbours = make([] uint128.Uint128, 81)
+    for cell := 0; cell < Nine * Nine; cell++ {
+        for g, mask := range masks {
+            if ! mask.And(Powers[cell]).IsZero() {
+                v.Unit_index[cell] = append(v.Unit_index[cell], g)
+                v.Neighbours[cell] = v.Neighbours[cell].Or(mask)
+            }
+        }
+        v.Neighbours[cell] = v.Neighbours[cell].And(Powers[cell].Not())
+    }
+
+    v.Crossings = make([][] int, len(masks))
+    for g := range masks {
+        for h := range masks {
+            if g != h && masks[g].And(masks[h]).OnesCount() >= 2 {
+                v.Crossings[g] = append(v.Crossings[g], h)
+            }
+        }
+    }
+    return v
+}
+
+func (v *Variant) Extend(name string, masks [] uint128.Uint128,
+    names [] string) *Variant {
+    // a new variant with the groups of 'v' and some more
+    return New_variant(name, append(append([] uint128.Uint128(nil),
+        v.Group_masks...), masks...), append(append([] string(nil),
+        v.Group_names...), names...))
+}
+
+func Diagonal() *Variant {
+    // X-Sudoku: both main diagonals hold every digit once
+    var main, anti uint128.Uint128
+    for i := 0; i < Nine; i++ {
+        main = main.Or(Powers[i * 9 + i])
+        anti = anti.Or(Powers[i * 9 + 8 - i])
+    }
+    return Classic.Extend("diagonal", [] uint128.Uint128 {main, anti},
+        [] string {"diagonal", "anti-diagonal"})
+}
+
+func Windoku() *Variant {
+    // Windoku or Hyper-Sudoku: four extra 3x3 windows with their top left
+    // corners at r2c2, r2c6, r6c2 and r6c6. Rows 1, 5, 9 and columns 1, 5, 9
+    // form five more groups with the window rows and columns, these are
+    // implied by the others, but give the solver more to work with.
+    var windows, implicit [] uint128.Uint128
+    var names [] string
+    lines := [3][3] int {{1, 2, 3}, {5, 6, 7}, {0, 4, 8}}
+    for i, rows := range lines {
+        for j, cols := range lines {
+            var mask uint128.Uint128
+            for _, r := range rows {
+                for _, c := range cols {
+                    mask = mask.Or(Powers[r * 9 + c])
+                }
+            }
+            if i < 2 && j < 2 {
+                windows = append(windows, mask)
+            } else {
+                implicit = append(implicit, mask)
+            }
+        }
+    }
+    for i := range windows {
+        names = append(names, fmt.Sprintf("window %d", i + 1))
+    }
+    for i := range implicit {
+        names = append(names, fmt.Sprintf("implicit window %d", i + 1))
+    }
+    return Classic.Extend("windoku", append(windows, implicit...), names)
+}
+
+func (v *Variant) Anti_knight() *Variant {
+    // a copy of 'v' where cells a knight's move apart may not hold the same
+    // digit
+    return v.with_moves("anti-knight", [][2] int {{-2, -1}, {-2, 1},
+        {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}})
+}
+
+func (v *Variant) Anti_king() *Variant {
+    // a copy of 'v' where cells a king's move apart may not hold the same
+    // digit; only the diagonal steps add something to rows and columns
+    return v.with_moves("anti-king", [][2] int {{-1, -1}, {-1, 1}, {1, -1},
+        {1, 1}})
+}
+
+func (v *Variant) with_moves(name string, moves [][2] int) *Variant {
+    // these constraints are no groups, they only add Neighbours
+    w := New_variant(v.Name + " " + name, v.Group_masks, v.Group_names)
+    for cell := 0; cell < Nine * Nine; cell++ {
+        w.Neighbours[cell] = v.Neighbours[cell]
+        r, c := cell / 9, cell % 9
+        for _, move := range moves {
+            rr, cc := r + move[0], c + move[1]
+            if rr >= 0 && rr < Nine && cc >= 0 && cc < Nine {
+                w.Neighbours[cell] = w.Neighbours[cell].Or(Powers[rr * 9 + cc])
+            }
+        }
+    }
+    return w
+}
+
+func (v *Variant) Distinct(name string, sets [][] int) *Variant {
+    // a copy of 'v' where the cells of every set may not repeat a digit,
+    // like the cages of a killer sudoku; the sets are no groups, they need
+    // not hold all 9 digits
+    w := New_variant(v.Name + " " + name, v.Group_masks, v.Group_names)
+    copy(w.Neighbours, v.Neighbours)
+    for _, set := range sets {
+        var mask uint128.Uint128
+        for _, cell := range set {
+            mask = mask.Or(Powers[cell])
+        }
+        for _, cell := range set {
+            w.Neighbours[cell] = w.Neighbours[cell].Or(
+                mask.And(Powers[cell].Not()))
+        }
+    }
+    return w
+}
+
+func Jigsaw(regions string) (*Variant, error) {
+    // Jigsaw sudoku: the boxes are replaced by nine irregular regions.
+    // 'regions' has 81 characters, one per cell, with nine different labels
+    // (any characters); the cells with the same label form a region, which
+    // must have 9 orthogonally connected cells. The regions are numbered in
+    // the order of their first cell, they take the places of the boxes.
+    var labels [] byte
+    var masks [] uint128.Uint128
+    if len(regions) != Nine * Nine {
+        return nil, fmt.Errorf("region map length %d, not 81", len(regions))
+    }
+    for cell := 0; cell < Nine * Nine; cell++ {
+        label := regions[cell]
+        index := bytes_index(labels, label)
+        if index < 0 {
+            if len(labels) == Nine {
+                return nil, fmt.Errorf("more than 9 regions")
+            }
+            labels = append(labels, label)
+            masks  = append(masks, uint128.Uint128{})
+            index  = len(labels) - 1
+        }
+        masks[index] = masks[index].Or(Powers[cell])
+    }
+    if len(labels) != Nine {
+        return nil, fmt.Errorf("%d regions, not 9", len(labels))
+    }
+
+    names := make([] string, 0, 27)
+    for i, mask := range masks {
+        if mask.OnesCount() != Nine {
+            return nil, fmt.Errorf("region %q has %d cells, not 9", labels[i],
+                mask.OnesCount())
+        }
+        if ! connected(mask) {
+            return nil, fmt.Errorf("region %q is not connected", labels[i])
+        }
+        names = append(names, fmt.Sprintf("region %d", i + 1))
+    }
+    names = append(names, Classic.Group_names[Nine:]...)
+    masks = append(masks, Group_masks[Nine:]...)
+    return New_variant("jigsaw", masks, names), nil
+}
+
+func connected(mask uint128.Uint128) bool {
+    // flood fill from the lowest cell of 'mask', orthogonal steps only
+    first := -1
+    for cell := 0; cell < Nine * Nine && first < 0; cell++ {
+        if ! mask.And(Powers[cell]).IsZero() {
+            first = cell
+        }
+    }
+    if first < 0 {
+        return false
+    }
+    reached := Powers[first]
+    todo    := [] int {first}
+    for len(todo) > 0 {
+        cell := todo[len(todo) - 1]
+        todo  = todo[:len(todo) - 1]
+        r, c := cell / 9, cell % 9
+        for _, next := range [4][2] int {{r - 1, c}, {r + 1, c}, {r, c - 1},
+            {r, c + 1}} {
+            if next[0] < 0 || next[0] >= Nine || next[1] < 0 ||
+                next[1] >= Nine {
+                continue
+            }
+            bit := Powers[next[0] * 9 + next[1]]
+            if mask.And(bit).IsZero() || ! reached.And(bit).IsZero() {
+                continue
+            }
+            reached = reached.Or(bit)
+            todo    = append(todo, next[0] * 9 + next[1])
+        }
+    }
+    return reached.Equals(mask)
+}
+
+func bytes_index(labels [] byte, label byte) int {
+    for i, l := range labels {
+        if l == label {
+            return i
+        }
+    }
+    return -1
 }
@@ -0,0 +1,221 @@
+package sudoku_dlx
+
+/* An exact cover solver for sudoku using Knuth's Dancing Links (DLX), see
+ * Donald E. Knuth, "Dancing Links", https://arxiv.org/abs/cs/0011047
+ *
+ * Sudoku as exact cover has 729 rows (a digit in a cell) and 324 columns
+ * (constraints), each row covers exactly 4 columns:
+ *   -   0 ..  80  cell 'cell' is filled
+ *   -  81 .. 161  row 'r' contains digit 'd'
+ *   - 162 .. 242  column 'c' contains digit 'd'
+ *   - 243 .. 323  box 'b' contains digit 'd'
+ *
+ * It serves as an independent cross-check for the bitmask solver in
+ * sudoku_solver and offers the same solve/count API.
+ * All state lives in a 'dlx' value, so concurrent use is fine.
+ */
+
+const Nine = 9
+const n_columns = 4 * Nine * Nine // 324
+const n_rows = Nine * Nine * Nine // 729
+
+type dlx struct {
+    // the four link arrays, index 0 is the root, 1..324 the column headers
+    left, right, up, down [] int
+    column  [] int // column header for every node
+    row     [] int // exact cover row (cell * 9 + digit - 1) for every node
+    size    [] int // number of nodes in every column
+    covered [] bool
+
+    // search state
+    solution [] int
+    count    int
+    limit    int
+    first    [81] int
+}
+
+func Solve_puzzle(puzzle string) ([81] int, bool) {
+    // return the first solution found, false if there is none
+    var empty [81] int
+    x := new_dlx(1)
+    if !x.load(puzzle) {
+        return empty, false
+    }
+    x.search()
+    if x.count == 0 {
+        return empty, false
+    }
+    return x.first, true
+}
+
+func Count_solutions(puzzle string, limit int) int {
+    // count the solutions, stop at 'limit' (0 means count them all)
+    x := new_dlx(limit)
+    if !x.load(puzzle) {
+        return 0
+    }
+    x.search()
+    return x.count
+}
+
+/*==============================================================================
+ *  build the matrix
+ *==============================================================================
+ */
+func new_dlx(limit int) *dlx {
+    n_nodes := 1 + n_columns + 4 * n_rows
+    x := &dlx{
+        left:    make([] int, n_nodes),
+        right:   make([] int, n_nodes),
+        up:      make([] int, n_nodes),
+        down:    make([] int, n_nodes),
+        column:  make([] int, n_nodes),
+        row:     make([] int, n_nodes),
+        size:    make([] int, n_columns + 1),
+        covered: make([] bool, n_columns + 1),
+        limit:   limit,
+    }
+
+    // circular list of column headers
+    for col := 0; col <= n_columns; col++ {
+        x.left[col]  = (col + n_columns) % (n_columns + 1)
+        x.right[col] = (col + 1) % (n_columns + 1)
+        x.up[col]    = col
+        x.down[col]  = col
+        x.column[col] = col
+    }
+
+    // one row of 4 nodes for every digit in every cell
+    node := n_columns + 1
+    for cell := 0; cell < Nine * Nine; cell++ {
+        for d := 1; d <= Nine; d++ {
+            cols := constraints(cell, d)
+            for i, col := range cols {
+                x.column[node] = col
+                x.row[node]    = cell * Nine + d - 1
+                x.left[node]   = node - i + (i + 3) % 4
+                x.right[node]  = node - i + (i + 1) % 4
+
+                // append at the bottom of the column
+                x.up[node]      = x.up[col]
+                x.down[node]    = col
+                x.down[x.up[col]] = node
+                x.up[col]       = node
+                x.size[col]++
+                node++
+            }
+        }
+    }
+    return x
+}
+
+func constraints(cell, digit int) [4] int {
+    // the column headers covered by 'digit' in 'cell'
+    r := cell / 9
+    c := cell % 9
+    b := r / 3 * 3 + c / 3
+    d := digit - 1
+    return [4] int {
+        1 + cell,
+        1 + 81  + r * 9 + d,
+        1 + 162 + c * 9 + d,
+        1 + 243 + b * 9 + d,
+    }
+}
+
+func (x *dlx) load(puzzle string) bool {
+    // select the rows for the givens, false if the givens contradict
+    if len(puzzle) < 81 {
+        panic("puzzle length not 81")
+    }
+
+    for cell := 0; cell < Nine * Nine; cell++ {
+        char := puzzle[cell]
+        if char < '1' || '9' < char {
+            continue
+        }
+        d := int(char - '0')
+        cols := constraints(cell, d)
+        for _, col := range cols {
+            if x.covered[col] {
+                return false
+            }
+        }
+        for _, col := range cols {
+            x.cover(col)
+        }
+        x.first[cell] = d
+    }
+    return true
+}
+
+/*==============================================================================
+ *  dancing links
+ *==============================================================================
+ */
+func (x *dlx) cover(col int) {
+    x.covered[col] = true
+    x.right[x.left[col]] = x.right[col]
+    x.left[x.right[col]] = x.left[col]
+    for i := x.down[col]; i != col; i = x.down[i] {
+        for j := x.right[i]; j != i; j = x.right[j] {
+            x.down[x.up[j]] = x.down[j]
+            x.up[x.down[j]] = x.up[j]
+            x.size[x.column[j]]--
+        }
+    }
+}
+
+func (x *dlx) uncover(col int) {
+    for i := x.up[col]; i != col; i = x.up[i] {
+        for j := x.left[i]; j != i; j = x.left[j] {
+            x.size[x.column[j]]++
+            x.down[x.up[j]] = j
+            x.up[x.down[j]] = j
+        }
+    }
+    x.right[x.left[col]] = col
+    x.left[x.right[col]] = col
+    x.covered[col] = false
+}
+
+func (x *dlx) search() {
+    // algorithm X, stops as soon as 'limit' solutions have been found
+    if x.right[0] == 0 {
+        if x.count == 0 {
+            for _, row := range x.solution {
+                x.first[row / Nine] = row % Nine + 1
+            }
+        }
+        x.count++
+        return
+    }
+
+    // choose the column with the fewest rows
+    best := x.right[0]
+    for col := x.right[best]; col != 0; col = x.right[col] {
+        if x.size[col] < x.size[best] {
+            best = col
+        }
+    }
+    if x.size[best] == 0 {
+        return
+    }
+
+    x.cover(best)
+    for i := x.down[best]; i != best; i = x.down[i] {
+        x.solution = append(x.solution, x.row[i])
+        for j := x.right[i]; j != i; j = x.right[j] {
+            x.cover(x.column[j])
+        }
+        x.search()
+        for j := x.left[i]; j != i; j = x.left[j] {
+            x.uncover(x.column[j])
+        }
+        x.solution = x.solution[:len(x.solution) - 1]
+        if x.limit > 0 && x.count >= x.limit {
+            break
+        }
+    }
+    x.uncover(best)
+}
@@ -0,0 +1,72 @@
+package sudoku_dlx_test
+
+/* Cross-check of the DLX solver against the bitmask solver of
+ * sudoku_solver. The test is an external package, since sudoku_solver
+ * imports sudoku_dlx.
+ */
+
+import (
+  "os"
+  "strings"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_dlx"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+func TestMain(m *testing.M) {
+    sudoku_solver.Setup_solver_once()
+    os.Exit(m.Run())
+}
+
+func TestAgainst_bitmask(t *testing.T) {
+    cases := [] struct {
+        name   string
+        puzzle string
+        count  int // the number of solutions
+    } {
+        {"unique",
+            "..3.2.6..9..3.5..1..18.64....81.29..7.......8..67.82....26.95..8..2.3..9..5.1.3..",
+            1},
+        {"hard",
+            "..25..........2.9.....6........7.8..7.4.9..1....3.6.4.2..4...3.4.....15.56.8..9..",
+            1},
+        // a solved grid with the digits of a rectangle taken away, they
+        // fit in two ways
+        {"multiple",
+            "4.3921.579.7345.21251876493548132976729564138136798245372689514814253769695417382",
+            2},
+        {"contradictory", "11" + strings.Repeat(".", 79), 0},
+        {"contradictory box", "1........" + ".1......." + strings.Repeat(".", 63),
+            0},
+    }
+    bitmask := sudoku_solver.New_solver()
+    bitmask.Backend = sudoku_solver.BITMASK
+
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            count := sudoku_dlx.Count_solutions(c.puzzle, 0)
+            if want := bitmask.Count_solutions(c.puzzle, 0); count != want {
+                t.Fatalf("DLX counts %d solutions, bitmask %d", count, want)
+            }
+            if count != c.count {
+                t.Fatalf("%d solutions, want %d", count, c.count)
+            }
+
+            grid, ok := sudoku_dlx.Solve_puzzle(c.puzzle)
+            want_grid, want_ok := bitmask.Solve_puzzle(c.puzzle)
+            switch {
+            case ok != want_ok:
+                t.Fatalf("DLX solves %v, bitmask %v", ok, want_ok)
+            case ! ok:
+                return
+            case count == 1 && grid != want_grid:
+                t.Errorf("the solutions differ:\n%v\n%v", grid, want_grid)
+            }
+            if err := sudoku_solver.Verify_against(c.puzzle, grid); err != nil {
+                t.Errorf("DLX solution: %v", err)
+            }
+        })
+    }
+}
@@ -0,0 +1,189 @@
+package sudoku_dots
+
+/* Dot and inequality constraints for sudoku_solver: relations between two
+ * orthogonally adjacent cells.
+ *
+ * - kropki: a white dot joins consecutive digits, a black dot digits where
+ *   one is twice the other
+ * - xv: an X joins digits adding up to 10, a V digits adding up to 5
+ * - greater: the first cell holds the greater digit
+ *
+ * With the negative constraint, all dots of a kind are given: where two
+ * adjacent cells have no kropki dot (or no X or V) between them, their
+ * digits may not have that relation either.
+ *
+ * Every pair is a constraint of its own. The relation is a table of the
+ * digits allowed next to each digit, so a pair keeps the candidates of each
+ * cell which have a partner among the candidates of the other cell.
+ */
+
+import (
+  "fmt"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+type Dot struct {
+    Kind string  // "white", "black", "x", "v" or "greater"
+    A, B int  // the cells; for "greater" A holds the greater digit
+}
+
+type Pair struct {
+    technique string
+    cells     [] int
+    // 'allowed[a]': the digits next to 'a', bit 'd' for digit 'd'
+    allowed   [10] uint16
+}
+
+func New_pair(technique string, a, b int, relation func(x, y int) bool) *Pair {
+    // the pair of cells 'a' and 'b', holding digits x and y with
+    // relation(x, y)
+    p := &Pair{technique: technique, cells: [] int {a, b}}
+    for x := 1; x <= Nine; x++ {
+        for y := 1; y <= Nine; y++ {
+            if relation(x, y) {
+                p.allowed[x] |= 1 << y
+            }
+        }
+    }
+    return p
+}
+
+func (p *Pair) Name() string {
+    return p.technique
+}
+
+func (p *Pair) Cells() [] int {
+    return p.cells
+}
+
+func (p *Pair) Restrict(candidates [] uint16) bool {
+    // keep the digits with a partner in the other cell
+    var first, second uint16
+    for x := 1; x <= Nine; x++ {
+        if candidates[0] & (1 << x) != 0 && p.allowed[x] & candidates[1] != 0 {
+            first  |= 1 << x
+            second |= p.allowed[x] & candidates[1]
+        }
+    }
+    candidates[0], candidates[1] = first, second
+    return first != 0
+}
+
+/*==============================================================================
+ *  the relations
+ *==============================================================================
+ */
+func relation(kind string) func(x, y int) bool {
+    switch kind {
+    case "white":
+        return func(x, y int) bool { return x - y == 1 || y - x == 1 }
+    case "black":
+        return func(x, y int) bool { return x == 2 * y || y == 2 * x }
+    case "x":
+        return func(x, y int) bool { return x + y == 10 }
+    case "v":
+        return func(x, y int) bool { return x + y == 5 }
+    case "greater":
+        return func(x, y int) bool { return x > y }
+    }
+    return nil
+}
+
+func family(kind string) string {
+    // the technique of a dot, the family of its negative constraint
+    switch kind {
+    case "white", "black":
+        return "kropki"
+    case "x", "v":
+        return "xv"
+    }
+    return kind
+}
+
+func Dots(dots [] Dot, negative [] string) ([] sudoku_solver.Constraint,
+    error) {
+    // the constraints of 'dots', and of the negative constraint of every
+    // family in 'negative'
+    var constraints [] sudoku_solver.Constraint
+    taken := map[[2] int] map[string] bool{}
+    for _, dot := range dots {
+        rel := relation(dot.Kind)
+        if rel == nil {
+            return nil, fmt.Errorf("unknown dot %q", dot.Kind)
+        }
+        if ! orthogonal(dot.A, dot.B) {
+            return nil, fmt.Errorf("%s: %s and %s are not side by side",
+                dot.Kind, name(dot.A), name(dot.B))
+        }
+        key := pair_key(dot.A, dot.B)
+        if taken[key] == nil {
+            taken[key] = map[string] bool{}
+        }
+        if taken[key][family(dot.Kind)] {
+            return nil, fmt.Errorf("%s: two dots between %s and %s",
+                dot.Kind, name(dot.A), name(dot.B))
+        }
+        taken[key][family(dot.Kind)] = true
+        constraints = append(constraints, New_pair(family(dot.Kind), dot.A,
+            dot.B, rel))
+    }
+
+    for _, fam := range negative {
+        var kinds [] string
+        switch fam {
+        case "kropki":
+            kinds = [] string {"white", "black"}
+        case "xv":
+            kinds = [] string {"x", "v"}
+        default:
+            return nil, fmt.Errorf("no negative constraint for %q", fam)
+        }
+        none := func(x, y int) bool {
+            for _, kind := range kinds {
+                if relation(kind)(x, y) {
+                    return false
+                }
+            }
+            return true
+        }
+        for cell := 0; cell < Nine * Nine; cell++ {
+            for _, next := range [2] int {cell + 1, cell + 9} {
+                if next >= Nine * Nine || ! orthogonal(cell, next) ||
+                    taken[pair_key(cell, next)][fam] {
+                    continue
+                }
+                constraints = append(constraints, New_pair("no " + fam, cell,
+                    next, none))
+            }
+        }
+    }
+    return constraints, nil
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func orthogonal(a, b int) bool {
+    // side by side in a row or a column
+    if a < 0 || a >= Nine * Nine || b < 0 || b >= Nine * Nine {
+        return false
+    }
+    dr, dc := a / 9 - b / 9, a % 9 - b % 9
+    return dr * dr + dc * dc == 1
+}
+
+func pair_key(a, b int) [2] int {
+    if a > b {
+        a, b = b, a
+    }
+    return [2] int {a, b}
+}
+
+func name(cell int) string {
+    return fmt.Sprintf("[%d%d]", cell / 9 + 1, cell % 9 + 1)
+}
@@ -0,0 +1,131 @@
+package sudoku_dots
+
+import (
+  "math/rand"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const SOLUTION = "483921657967345821251876493548132976729564138136798245372689514814253769695417382"
+
+// all digits 1..9, bit 'd' for digit 'd'
+const ALL_DIGITS = uint16(0x3fe)
+
+func TestRestrict(t *testing.T) {
+    no_kropki, err := Dots(nil, [] string {"kropki"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    cases := [] struct {
+        name  string
+        pair  sudoku_solver.Constraint
+        given [2] uint16 // zero: all digits
+        want  [2] uint16 // zero: nothing left
+    } {
+        {"white 5", New_pair("kropki", 0, 1, relation("white")),
+            [2] uint16 {0x020, 0}, [2] uint16 {0x020, 0x050}},
+        {"white 1 or 9", New_pair("kropki", 0, 1, relation("white")),
+            [2] uint16 {0x202, 0x3fc}, [2] uint16 {0x202, 0x104}},
+        {"black", New_pair("kropki", 0, 1, relation("black")),
+            [2] uint16 {}, [2] uint16 {0x15e, 0x15e}},
+        {"black 5", New_pair("kropki", 0, 1, relation("black")),
+            [2] uint16 {0x020, 0}, [2] uint16 {}},
+        {"x", New_pair("xv", 0, 1, relation("x")),
+            [2] uint16 {0x006, 0}, [2] uint16 {0x006, 0x300}},
+        {"v", New_pair("xv", 0, 1, relation("v")),
+            [2] uint16 {}, [2] uint16 {0x01e, 0x01e}},
+        {"greater", New_pair("greater", 0, 1, relation("greater")),
+            [2] uint16 {}, [2] uint16 {0x3fc, 0x1fe}},
+        {"no kropki 5", no_kropki[0], [2] uint16 {0x020, 0},
+            [2] uint16 {0x020, ALL_DIGITS &^ 0x050}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            candidates := [] uint16 {ALL_DIGITS, ALL_DIGITS}
+            for i := range candidates {
+                if c.given[i] != 0 {
+                    candidates[i] = c.given[i]
+                }
+            }
+            ok := c.pair.Restrict(candidates)
+            switch {
+            case c.want[0] == 0 && ok:
+                t.Errorf("%03x left, want nothing", candidates)
+            case c.want[0] == 0:
+            case ! ok:
+                t.Errorf("nothing left, want %03x", c.want)
+            case candidates[0] != c.want[0] || candidates[1] != c.want[1]:
+                t.Errorf("%03x left, want %03x", candidates, c.want)
+            }
+        })
+    }
+}
+
+func TestSound(t *testing.T) {
+    // every dot which fits a known solution, with the negative constraints:
+    // no pair may remove a digit of the solution from any candidates
+    // containing it
+    var solution [81] int
+    var dots [] Dot
+    for cell := range solution {
+        solution[cell] = int(SOLUTION[cell] - '0')
+    }
+    for cell := 0; cell < Nine * Nine; cell++ {
+        for _, next := range [2] int {cell + 1, cell + 9} {
+            if ! orthogonal(cell, next) {
+                continue
+            }
+            a, b := solution[cell], solution[next]
+            for _, kinds := range [][] string {{"white", "black"}, {"x", "v"}} {
+                for _, kind := range kinds {
+                    if relation(kind)(a, b) {
+                        dots = append(dots, Dot{Kind: kind, A: cell, B: next})
+                        break
+                    }
+                }
+            }
+            if a > b {
+                dots = append(dots, Dot{Kind: "greater", A: cell, B: next})
+            } else {
+                dots = append(dots, Dot{Kind: "greater", A: next, B: cell})
+            }
+        }
+    }
+    pairs, err := Dots(dots, [] string {"kropki", "xv"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    techniques := map[string] int{}
+    for _, pair := range pairs {
+        techniques[pair.Name()]++
+    }
+    for _, technique := range [] string {"kropki", "xv", "greater",
+        "no kropki", "no xv"} {
+        if techniques[technique] == 0 {
+            t.Errorf("no %s pair to check", technique)
+        }
+    }
+
+    rng := rand.New(rand.NewSource(1))
+    for round := 0; round < 50; round++ {
+        for _, pair := range pairs {
+            cells := pair.Cells()
+            candidates := make([] uint16, len(cells))
+            for i, cell := range cells {
+                candidates[i] = uint16(rng.Intn(1 << 10)) & ALL_DIGITS |
+                    1 << solution[cell]
+            }
+            if ! pair.Restrict(candidates) {
+                t.Fatalf("%s %v: nothing left", pair.Name(), cells)
+            }
+            for i, cell := range cells {
+                if candidates[i] & (1 << solution[cell]) == 0 {
+                    t.Fatalf("%s %v: removes %d from cell %d", pair.Name(),
+                        cells, solution[cell], cell)
+                }
+            }
+        }
+    }
+}
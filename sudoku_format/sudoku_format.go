@@ -0,0 +1,143 @@
+package sudoku_format
+
+/* Text renderers for solved or partial grids.
+ *
+ * A grid is 81 ints as returned by sudoku_solver.Contents(), 0 for an empty
+ * cell. The candidates for the pencil mark grid are the per digit cell masks
+ * returned by sudoku_solver.Locations().
+ *
+ * - Line          81 characters, '.' for an empty cell
+ * - Boxed         9 x 9 grid with ASCII borders
+ * - Unicode       9 x 9 grid with box drawing characters
+ * - Pencil_marks  27 x 27 grid, every cell shows its remaining candidates
+ *                 in a 3 x 3 block
+ *
+ * Cell_name and Cell_names give the "[rc]" cell names used in debug output.
+ * Pencil_marks and Cell_names need sudoku_constants.Setup_sudoku_constants.
+ */
+
+import (
+  "fmt"
+  "strings"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+)
+
+const Nine = 9
+
+func Line(grid [81] int) string {
+    // compact 81 character format
+    var sb strings.Builder
+    for cell := 0; cell < Nine * Nine; cell++ {
+        sb.WriteByte(digit_char(grid[cell]))
+    }
+    return sb.String()
+}
+
+func Boxed(grid [81] int) string {
+    // +-------+-------+-------+
+    // | . . 3 | . 2 . | 6 . . |
+    separator := "+-------+-------+-------+\n"
+    return render(grid, separator, separator, separator, "|")
+}
+
+func Unicode(grid [81] int) string {
+    // ┌───────┬───────┬───────┐
+    // │ . . 3 │ . 2 . │ 6 . . │
+    return render(grid,
+        "┌───────┬───────┬───────┐\n",
+        "├───────┼───────┼───────┤\n",
+        "└───────┴───────┴───────┘\n",
+        "│")
+}
+
+func render(grid [81] int, top, middle, bottom, bar string) string {
+    // lay out 9 rows, a horizontal line after every band
+    var sb strings.Builder
+    sb.WriteString(top)
+    for r := 0; r < Nine; r++ {
+        if r > 0 && r % 3 == 0 {
+            sb.WriteString(middle)
+        }
+        for c := 0; c < Nine; c++ {
+            if c % 3 == 0 {
+                sb.WriteString(bar)
+            }
+            sb.WriteByte(' ')
+            sb.WriteByte(digit_char(grid[r * 9 + c]))
+            if c % 3 == 2 {
+                sb.WriteByte(' ')
+            }
+        }
+        sb.WriteString(bar + "\n")
+    }
+    sb.WriteString(bottom)
+    return sb.String()
+}
+
+func Pencil_marks(grid [81] int, locations [10] uint128.Uint128) string {
+    // every cell is a 3 x 3 block showing candidates 1..9, '.' for a
+    // candidate which has been removed. A filled cell shows its digit in the
+    // middle of the block.
+    var sb strings.Builder
+    separator := "+-------------+-------------+-------------+\n"
+
+    sb.WriteString(separator)
+    for r := 0; r < Nine; r++ {
+        if r > 0 && r % 3 == 0 {
+            sb.WriteString(separator)
+        }
+        // three lines of text per row of cells
+        for line := 0; line < 3; line++ {
+            for c := 0; c < Nine; c++ {
+                if c % 3 == 0 {
+                    sb.WriteString("| ")
+                }
+                cell := r * 9 + c
+                for i := 0; i < 3; i++ {
+                    d := line * 3 + i + 1
+                    switch {
+                    case grid[cell] != 0 && line == 1 && i == 1:
+                        sb.WriteByte(digit_char(grid[cell]))
+                    case grid[cell] != 0:
+                        sb.WriteByte(' ')
+                    case ! locations[d].And(
+                        sudoku_constants.Powers[cell]).IsZero():
+                        sb.WriteByte(digit_char(d))
+                    default:
+                        sb.WriteByte('.')
+                    }
+                }
+                sb.WriteByte(' ')
+            }
+            sb.WriteString("|\n")
+        }
+    }
+    sb.WriteString(separator)
+    return sb.String()
+}
+
+func Cell_name(cell int) string {
+    // convert linear 'cell' to a two-dimensional sudoku address
+    return fmt.Sprintf("[%d%d]", cell / 9 + 1, cell % 9 + 1)
+}
+
+func Cell_names(mask uint128.Uint128) [] string {
+    // the names of all cells in 'mask'
+    var names [] string
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if ! mask.And(sudoku_constants.Powers[cell]).IsZero() {
+            names = append(names, Cell_name(cell))
+        }
+    }
+    return names
+}
+
+func digit_char(digit int) byte {
+    if digit < 1 || digit > Nine {
+        return '.'
+    }
+    return byte('0' + digit)
+}
@@ -0,0 +1,416 @@
+package sudoku_game
+
+/* A play session: the givens, the player's entries and pencil marks, the
+ * moves for undo and redo, the clock and the mistakes.
+ *
+ * - a digit conflicts if one of its Neighbours holds the same digit; that is
+ *   what the player can see
+ * - a mistake is an entry which differs from the solution, whether it
+ *   conflicts or not. Mistakes are counted when they are made, undo does
+ *   not take them back.
+ * - the clock runs from New_session until the puzzle is solved, except while
+ *   the session is paused
+ * - a session is saved and restored with encoding/json
+ *
+ * The puzzle must have a unique solution, it is found with sudoku_solver, so
+ * sudoku_solver.Setup_solver_once must have been called.
+ */
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "time"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+var (
+    ErrRange  = errors.New("cell or digit out of range")
+    ErrGiven  = errors.New("the cell holds a given")
+    ErrFilled = errors.New("pencil marks need an empty cell")
+)
+
+// A Move changes one cell, it remembers the cell before and after, so that
+// it can be undone and redone.
+type Move struct {
+    Cell      int    `json:"cell"`
+    Value     int    `json:"value"`     // the entry after the move, 0 for none
+    Marks     uint16 `json:"marks"`     // bit 'd' set: pencil mark 'd'
+    Old_value int    `json:"old_value"`
+    Old_marks uint16 `json:"old_marks"`
+}
+
+type Session struct {
+    puzzle   string
+    givens   [81] int
+    solution [81] int
+    entries  [81] int
+    marks    [81] uint16
+    history  [] Move
+    future   [] Move
+    mistakes int
+
+    elapsed  time.Duration // up to 'since'
+    since    time.Time     // start of the running period, zero when stopped
+}
+
+func New_session(puzzle string) (*Session, error) {
+    s := &Session{}
+    if err := s.load(puzzle); err != nil {
+        return nil, err
+    }
+    s.since = time.Now()
+    return s, nil
+}
+
+func (s *Session) load(puzzle string) error {
+    if len(puzzle) < 81 {
+        return fmt.Errorf("puzzle length not 81")
+    }
+    solver := sudoku_solver.New_solver()
+    switch solver.Count_solutions(puzzle, 2) {
+    case 0:
+        return fmt.Errorf("the puzzle has no solution")
+    case 2:
+        return fmt.Errorf("the puzzle has more than one solution")
+    }
+    s.solution, _ = solver.Solve_puzzle(puzzle)
+    s.puzzle = puzzle[:81]
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if '1' <= puzzle[cell] && puzzle[cell] <= '9' {
+            s.givens[cell] = int(puzzle[cell] - '0')
+        }
+    }
+    return nil
+}
+
+/*==============================================================================
+ *  moves
+ *==============================================================================
+ */
+func (s *Session) Enter(cell, digit int) error {
+    // put 'digit' into 'cell', its pencil marks go
+    if err := s.check(cell, digit); err != nil {
+        return err
+    }
+    if s.entries[cell] == digit {
+        return nil
+    }
+    if digit != s.solution[cell] {
+        s.mistakes++
+    }
+    s.apply(Move{Cell: cell, Value: digit})
+    return nil
+}
+
+func (s *Session) Toggle_mark(cell, digit int) error {
+    if err := s.check(cell, digit); err != nil {
+        return err
+    }
+    if s.entries[cell] != 0 {
+        return ErrFilled
+    }
+    s.apply(Move{Cell: cell, Marks: s.marks[cell] ^ 1 << digit})
+    return nil
+}
+
+func (s *Session) Erase(cell int) error {
+    // remove the entry and the pencil marks of 'cell'
+    if err := s.check(cell, 1); err != nil {
+        return err
+    }
+    if s.entries[cell] != 0 || s.marks[cell] != 0 {
+        s.apply(Move{Cell: cell})
+    }
+    return nil
+}
+
+func (s *Session) Undo() bool {
+    if len(s.history) == 0 {
+        return false
+    }
+    solved := s.Solved()
+    move := s.history[len(s.history) - 1]
+    s.history = s.history[:len(s.history) - 1]
+    s.future  = append(s.future, move)
+    s.entries[move.Cell] = move.Old_value
+    s.marks[move.Cell]   = move.Old_marks
+    s.update_clock(solved)
+    return true
+}
+
+func (s *Session) Redo() bool {
+    if len(s.future) == 0 {
+        return false
+    }
+    solved := s.Solved()
+    move := s.future[len(s.future) - 1]
+    s.future  = s.future[:len(s.future) - 1]
+    s.history = append(s.history, move)
+    s.entries[move.Cell] = move.Value
+    s.marks[move.Cell]   = move.Marks
+    s.update_clock(solved)
+    return true
+}
+
+func (s *Session) History() [] Move {
+    return append([] Move(nil), s.history...)
+}
+
+func (s *Session) apply(move Move) {
+    // a new move ends what could be redone
+    solved := s.Solved()
+    move.Old_value = s.entries[move.Cell]
+    move.Old_marks = s.marks[move.Cell]
+    s.entries[move.Cell] = move.Value
+    s.marks[move.Cell]   = move.Marks
+    s.history = append(s.history, move)
+    s.future  = nil
+    s.update_clock(solved)
+}
+
+func (s *Session) check(cell, digit int) error {
+    if cell < 0 || cell >= Nine * Nine || digit < 1 || digit > Nine {
+        return ErrRange
+    }
+    if s.givens[cell] != 0 {
+        return ErrGiven
+    }
+    return nil
+}
+
+/*==============================================================================
+ *  the position
+ *==============================================================================
+ */
+func (s *Session) Puzzle() string {
+    return s.puzzle
+}
+
+func (s *Session) Given(cell int) bool {
+    return s.givens[cell] != 0
+}
+
+func (s *Session) Value(cell int) int {
+    // the given or the entry in 'cell', 0 if empty
+    if s.givens[cell] != 0 {
+        return s.givens[cell]
+    }
+    return s.entries[cell]
+}
+
+func (s *Session) Marks(cell int) [] int {
+    var marks [] int
+    for d := 1; d <= Nine; d++ {
+        if s.marks[cell] & (1 << d) != 0 {
+            marks = append(marks, d)
+        }
+    }
+    return marks
+}
+
+func (s *Session) Grid() [81] int {
+    var grid [81] int
+    for cell := range grid {
+        grid[cell] = s.Value(cell)
+    }
+    return grid
+}
+
+func (s *Session) Position() string {
+    // givens and entries as an 81 character line, '.' for empty
+    line := make([] byte, Nine * Nine)
+    for cell := range line {
+        line[cell] = '.'
+        if value := s.Value(cell); value != 0 {
+            line[cell] = byte('0' + value)
+        }
+    }
+    return string(line)
+}
+
+func (s *Session) Conflict(cell int) bool {
+    // another cell in the same group holds the digit of 'cell'
+    value := s.Value(cell)
+    if value == 0 {
+        return false
+    }
+    return ! s.placed(value).And(sudoku_constants.Neighbours[cell]).IsZero()
+}
+
+func (s *Session) Conflicts() [] int {
+    var cells [] int
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if s.Conflict(cell) {
+            cells = append(cells, cell)
+        }
+    }
+    return cells
+}
+
+func (s *Session) Wrong(cell int) bool {
+    // the entry in 'cell' differs from the solution
+    return s.entries[cell] != 0 && s.entries[cell] != s.solution[cell]
+}
+
+func (s *Session) Mistakes() int {
+    return s.mistakes
+}
+
+func (s *Session) Solved() bool {
+    return s.Grid() == s.solution
+}
+
+func (s *Session) Solution() [81] int {
+    return s.solution
+}
+
+func (s *Session) Hint() (sudoku_solver.Step, bool) {
+    // the next step of the solver from the current position. Wrong entries
+    // are ignored, the hint is based on the correct ones only.
+    position := make([] byte, Nine * Nine)
+    for cell := range position {
+        position[cell] = '.'
+        if value := s.Value(cell); value != 0 && ! s.Wrong(cell) {
+            position[cell] = byte('0' + value)
+        }
+    }
+    solver := sudoku_solver.New_solver()
+    solver.Record = true
+    solver.Start_solver(string(position))
+    if len(solver.Steps) == 0 {
+        return sudoku_solver.Step{}, false
+    }
+    return solver.Steps[0], true
+}
+
+func (s *Session) placed(digit int) uint128.Uint128 {
+    // all cells holding 'digit'
+    var mask uint128.Uint128
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if s.Value(cell) == digit {
+            mask = mask.Or(sudoku_constants.Powers[cell])
+        }
+    }
+    return mask
+}
+
+/*==============================================================================
+ *  the clock
+ *==============================================================================
+ */
+func (s *Session) Elapsed() time.Duration {
+    if s.since.IsZero() {
+        return s.elapsed
+    }
+    return s.elapsed + time.Since(s.since)
+}
+
+func (s *Session) Pause() {
+    if ! s.since.IsZero() {
+        s.elapsed = s.Elapsed()
+        s.since   = time.Time{}
+    }
+}
+
+func (s *Session) Resume() {
+    if s.since.IsZero() && ! s.Solved() {
+        s.since = time.Now()
+    }
+}
+
+func (s *Session) Paused() bool {
+    return s.since.IsZero()
+}
+
+func (s *Session) update_clock(was_solved bool) {
+    // after a move: the clock stops when the grid is solved, and goes on
+    // when a move takes a solved grid apart again
+    switch {
+    case s.Solved():
+        s.Pause()
+    case was_solved:
+        s.Resume()
+    }
+}
+
+/*==============================================================================
+ *  saving and restoring
+ *==============================================================================
+ */
+type saved struct {
+    Puzzle   string   `json:"puzzle"`
+    Entries  string   `json:"entries"`
+    Marks    [] int   `json:"marks"`   // per cell, bit 'd' for digit 'd'
+    History  [] Move  `json:"history"`
+    Future   [] Move  `json:"future"`
+    Mistakes int      `json:"mistakes"`
+    Elapsed  int64    `json:"elapsed_ms"`
+    Paused   bool     `json:"paused"`
+}
+
+func (s *Session) MarshalJSON() ([] byte, error) {
+    entries := make([] byte, Nine * Nine)
+    marks   := make([] int, Nine * Nine)
+    for cell := range entries {
+        entries[cell] = byte('0' + s.entries[cell])
+        marks[cell]   = int(s.marks[cell])
+    }
+    return json.Marshal(saved{Puzzle: s.puzzle, Entries: string(entries),
+        Marks: marks, History: s.history, Future: s.future,
+        Mistakes: s.mistakes, Elapsed: s.Elapsed().Milliseconds(),
+        Paused: s.Paused()})
+}
+
+func (s *Session) UnmarshalJSON(data [] byte) error {
+    // the session continues where it was saved, the clock runs unless it
+    // was paused
+    var in saved
+    var fresh Session
+    if err := json.Unmarshal(data, &in); err != nil {
+        return err
+    }
+    if err := fresh.load(in.Puzzle); err != nil {
+        return err
+    }
+    if len(in.Entries) != Nine * Nine || len(in.Marks) != Nine * Nine {
+        return fmt.Errorf("entries or marks not for 81 cells")
+    }
+    for cell := 0; cell < Nine * Nine; cell++ {
+        value := int(in.Entries[cell]) - '0'
+        if value < 0 || value > Nine || in.Marks[cell] < 0 ||
+            in.Marks[cell] >= 1 << (Nine + 1) ||
+            (fresh.givens[cell] != 0 && (value != 0 || in.Marks[cell] != 0)) {
+            return fmt.Errorf("cell %d: bad entry or marks", cell)
+        }
+        fresh.entries[cell] = value
+        fresh.marks[cell]   = uint16(in.Marks[cell])
+    }
+    for _, move := range append(in.History, in.Future...) {
+        if move.Cell < 0 || move.Cell >= Nine * Nine ||
+            move.Value < 0 || move.Value > Nine ||
+            move.Old_value < 0 || move.Old_value > Nine ||
+            move.Marks >= 1 << (Nine + 1) || move.Old_marks >= 1 << (Nine + 1) {
+            return ErrRange
+        }
+        if fresh.givens[move.Cell] != 0 {
+            return ErrGiven
+        }
+    }
+
+    fresh.history  = in.History
+    fresh.future   = in.Future
+    fresh.mistakes = in.Mistakes
+    fresh.elapsed  = time.Duration(in.Elapsed) * time.Millisecond
+    if ! in.Paused && ! fresh.Solved() {
+        fresh.since = time.Now()
+    }
+    *s = fresh
+    return nil
+}
@@ -0,0 +1,86 @@
+package sudoku_game
+
+import (
+  "os"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+// an easy puzzle with a unique solution
+const EASY = "..3.2.6..9..3.5..1..18.64....81.29..7.......8..67.82....26.95..8..2.3..9..5.1.3.."
+
+func TestMain(m *testing.M) {
+    sudoku_solver.Setup_solver_once()
+    os.Exit(m.Run())
+}
+
+func solved_session(t *testing.T) (*Session, int) {
+    // a session with all cells filled in, and the last cell entered
+    s, err := New_session(EASY)
+    if err != nil {
+        t.Fatal(err)
+    }
+    solution := s.Solution()
+    last := -1
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if ! s.Given(cell) {
+            if err := s.Enter(cell, solution[cell]); err != nil {
+                t.Fatal(err)
+            }
+            last = cell
+        }
+    }
+    if ! s.Solved() || ! s.Paused() {
+        t.Fatal("the clock goes on after the grid is solved")
+    }
+    return s, last
+}
+
+func TestClock(t *testing.T) {
+    cases := [] struct {
+        name string
+        move func(s *Session, cell int) error
+    } {
+        {"erase", func(s *Session, cell int) error {
+            return s.Erase(cell)
+        }},
+        {"enter", func(s *Session, cell int) error {
+            return s.Enter(cell, s.Solution()[cell] % Nine + 1)
+        }},
+        {"undo", func(s *Session, cell int) error {
+            s.Undo()
+            return nil
+        }},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            s, last := solved_session(t)
+            if err := c.move(s, last); err != nil {
+                t.Fatal(err)
+            }
+            if s.Solved() || s.Paused() {
+                t.Errorf("solved %v, paused %v after the move", s.Solved(),
+                    s.Paused())
+            }
+
+            // solved again: the clock stops once more
+            if err := s.Enter(last, s.Solution()[last]); err != nil {
+                t.Fatal(err)
+            }
+            if ! s.Solved() || ! s.Paused() {
+                t.Error("the clock goes on after the grid is solved again")
+            }
+        })
+    }
+}
+
+func TestClock_redo(t *testing.T) {
+    s, _ := solved_session(t)
+    s.Undo()
+    s.Redo()
+    if ! s.Solved() || ! s.Paused() {
+        t.Error("the clock goes on after redo solves the grid")
+    }
+}
@@ -0,0 +1,74 @@
+package sudoku_generator
+
+/* Generate puzzles with a unique solution.
+ *
+ * A random full grid is built by filling the three boxes on the main
+ * diagonal with random permutations (they do not see each other), solving
+ * the rest and disguising the result with a random transform.
+ * Then the givens are removed one by one in random order, as long as the
+ * puzzle keeps a unique solution. The result is minimal: no given can be
+ * removed any more. With 'symmetric' set, givens are removed in pairs which
+ * are symmetric under a rotation by 180 degrees, and only the pairs are
+ * minimal.
+ *
+ * The solutions are counted with sudoku_solver, so
+ * sudoku_solver.Setup_solver_once must have been called.
+ */
+
+import (
+  "math/rand"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+  "github.com/wplapper/go-sudoku3/sudoku_transform"
+)
+
+const Nine = 9
+
+func Full_grid(rng *rand.Rand) [81] int {
+    // a random valid solution grid
+    puzzle := make([] byte, Nine * Nine)
+    for cell := range puzzle {
+        puzzle[cell] = '.'
+    }
+    for box := 0; box < 3; box++ {
+        for i, d := range rng.Perm(Nine) {
+            r := box * 3 + i / 3
+            c := box * 3 + i % 3
+            puzzle[r * 9 + c] = byte('1' + d)
+        }
+    }
+
+    grid, ok := sudoku_solver.Solve_puzzle(string(puzzle))
+    if ! ok {
+        panic("no solution for a full grid")
+    }
+    return sudoku_transform.Random(rng).Apply_grid(grid)
+}
+
+func Generate(rng *rand.Rand, symmetric bool) (string, [81] int) {
+    // a minimal puzzle with a unique solution, and that solution
+    solution := Full_grid(rng)
+    puzzle := make([] byte, Nine * Nine)
+    for cell := range puzzle {
+        puzzle[cell] = byte('0' + solution[cell])
+    }
+
+    for _, cell := range rng.Perm(Nine * Nine) {
+        partner := Nine * Nine - 1 - cell
+        if puzzle[cell] == '.' {
+            continue
+        }
+
+        saved_cell, saved_partner := puzzle[cell], puzzle[partner]
+        puzzle[cell] = '.'
+        if symmetric {
+            puzzle[partner] = '.'
+        }
+        if sudoku_solver.Count_solutions(string(puzzle), 2) != 1 {
+            puzzle[cell] = saved_cell
+            puzzle[partner] = saved_partner
+        }
+    }
+    return string(puzzle), solution
+}
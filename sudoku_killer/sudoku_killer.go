@@ -0,0 +1,289 @@
+package sudoku_killer
+
+/* Killer sudoku: the grid is divided into cages, sets of cells whose digits
+ * add up to the sum of the cage and may not repeat.
+ *
+ * Killer turns the cages into constraints for sudoku_solver:
+ * - every cage is a Sum, its candidates are restricted to the combinations
+ *   adding up to the cage sum (technique "cage")
+ * - the 45 rule: the digits of a group add up to 45, of n groups to n * 45.
+ *   For every group and every run of consecutive rows or columns, the cells
+ *   not covered by the cages inside of it (the innies) have a known sum, and
+ *   so have the cells of the cages sticking out of it (the outies), if the
+ *   cages cover it completely. Small innies and outies become a Sum of their
+ *   own (techniques "innies" and "outies").
+ * - the cells of a cage see each other, the variant gets them as Neighbours
+ *
+ * The search of the solver needs nothing special: every guess is followed by
+ * the solver functions, 'constrain' included, and a complete grid is checked
+ * against all constraints.
+ */
+
+import (
+  "fmt"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+// innies and outies with more cells say too little to be worth a Sum
+const MAX_DERIVED = 5
+
+type Cage struct {
+    Sum   int
+    Cells [] int
+}
+
+// A Sum is a constraint: the digits of its cells add up to 'total'. With
+// 'distinct' set, a digit may not repeat, as in a cage.
+type Sum struct {
+    technique string
+    cells     [] int
+    total     int
+    distinct  bool
+}
+
+func New_sum(technique string, cells [] int, total int, distinct bool) *Sum {
+    return &Sum{technique: technique, cells: cells, total: total,
+        distinct: distinct}
+}
+
+func (s *Sum) Name() string {
+    return s.technique
+}
+
+func (s *Sum) Cells() [] int {
+    return s.cells
+}
+
+func (s *Sum) Total() int {
+    return s.total
+}
+
+func (s *Sum) Restrict(candidates [] uint16) bool {
+    // keep the candidates which are part of a combination adding up to
+    // 'total'. The combinations are walked cell by cell, a state is the
+    // cell reached and the digits used so far (or their sum, if digits may
+    // repeat), and every state is explored only once.
+    n := len(candidates)
+    support := make([] uint16, n)
+    // 0: not seen yet, 1: leads to 'total', 2: does not
+    seen := make([] int8, n << 10)
+
+    var walk func(i int, used uint16, sum int) bool
+    walk = func(i int, used uint16, sum int) bool {
+        if i == n {
+            return sum == s.total
+        }
+        key := i << 10 | int(used)
+        if ! s.distinct {
+            key = i << 10 | sum
+        }
+        if seen[key] != 0 {
+            return seen[key] == 1
+        }
+
+        seen[key] = 2
+        for d := 1; d <= Nine && sum + d <= s.total; d++ {
+            bit := uint16(1) << d
+            if candidates[i] & bit == 0 || (s.distinct && used & bit != 0) {
+                continue
+            }
+            if walk(i + 1, used | bit, sum + d) {
+                support[i] |= bit
+                seen[key] = 1
+            }
+        }
+        return seen[key] == 1
+    }
+
+    if s.total >= 1 << 10 || ! walk(0, 0, 0) {
+        return false
+    }
+    copy(candidates, support)
+    return true
+}
+
+/*==============================================================================
+ *  the cages of a puzzle
+ *==============================================================================
+ */
+func Check(cages [] Cage) error {
+    // nil if the cages are well formed: 1..9 cells each, no cell in two
+    // cages, and a sum which 9 different digits can make
+    var used uint128.Uint128
+    for _, cage := range cages {
+        n := len(cage.Cells)
+        if n < 1 || n > Nine {
+            return fmt.Errorf("cage %d: %d cells", cage.Sum, n)
+        }
+        low, high := n * (n + 1) / 2, n * (19 - n) / 2
+        if cage.Sum < low || cage.Sum > high {
+            return fmt.Errorf("cage %d: %d cells add up to %d..%d", cage.Sum,
+                n, low, high)
+        }
+        for _, cell := range cage.Cells {
+            if cell < 0 || cell >= Nine * Nine {
+                return fmt.Errorf("cage %d: no cell %d", cage.Sum, cell)
+            }
+            bit := sudoku_constants.Powers[cell]
+            if ! used.And(bit).IsZero() {
+                return fmt.Errorf("cage %d: cell [%d%d] in two cages",
+                    cage.Sum, cell / 9 + 1, cell % 9 + 1)
+            }
+            used = used.Or(bit)
+        }
+    }
+    return nil
+}
+
+func Killer(variant *sudoku_constants.Variant, cages [] Cage) (
+    *sudoku_constants.Variant, [] sudoku_solver.Constraint, error) {
+    // the variant with the cages added as Neighbours, and the constraints
+    // of the cages and of the 45 rule
+    var constraints [] sudoku_solver.Constraint
+    if err := Check(cages); err != nil {
+        return nil, nil, err
+    }
+
+    sets  := make([][] int, len(cages))
+    masks := make([] uint128.Uint128, len(cages))
+    known := map[uint128.Uint128] bool{}
+    for i, cage := range cages {
+        sets[i]  = cage.Cells
+        masks[i] = cells_mask(cage.Cells)
+        known[masks[i]] = true
+        constraints = append(constraints, New_sum("cage", cage.Cells,
+            cage.Sum, true))
+    }
+    variant = variant.Distinct("killer", sets)
+
+    add := func(technique string, mask uint128.Uint128, total int) {
+        n := mask.OnesCount()
+        if n == 0 || n > MAX_DERIVED || known[mask] {
+            return
+        }
+        known[mask] = true
+        cells := mask_cells(mask)
+        constraints = append(constraints, New_sum(technique, cells, total,
+            distinct(variant, cells)))
+    }
+
+    for _, region := range regions(variant) {
+        var inside, partial uint128.Uint128
+        inside_sum, partial_sum := 0, 0
+        for i, mask := range masks {
+            switch {
+            case mask.And(region.mask).Equals(mask):
+                inside = inside.Or(mask)
+                inside_sum += cages[i].Sum
+            case ! mask.And(region.mask).IsZero():
+                partial = partial.Or(mask)
+                partial_sum += cages[i].Sum
+            }
+        }
+        add("innies", region.mask.And(inside.Not()), region.sum - inside_sum)
+        if ! partial.IsZero() &&
+            region.mask.And(inside.Or(partial).Not()).IsZero() {
+            add("outies", partial.And(region.mask.Not()),
+                inside_sum + partial_sum - region.sum)
+        }
+    }
+    return variant, constraints, nil
+}
+
+func New_solver(variant *sudoku_constants.Variant, cages [] Cage) (
+    *sudoku_solver.Solver, error) {
+    // a solver for the killer sudoku with 'cages' on top of 'variant'
+    variant, constraints, err := Killer(variant, cages)
+    if err != nil {
+        return nil, err
+    }
+    solver := sudoku_solver.New_variant_solver(variant)
+    solver.Add_constraint(constraints...)
+    return solver, nil
+}
+
+/*==============================================================================
+ *  the regions of the 45 rule
+ *==============================================================================
+ */
+type region struct {
+    mask uint128.Uint128
+    sum  int
+}
+
+func regions(variant *sudoku_constants.Variant) [] region {
+    // every group of the variant, and the runs of 2..9 consecutive rows and
+    // columns, as far as they are groups of the variant
+    var result [] region
+    for _, mask := range variant.Group_masks {
+        result = append(result, region{mask, 45})
+    }
+
+    for _, first := range [2] int {Nine, Nine * 2} {
+        for start := first; start < first + Nine; start++ {
+            mask := sudoku_constants.Group_masks[start]
+            if ! has_group(variant, mask) {
+                continue
+            }
+            for end := start + 1; end < first + Nine; end++ {
+                line := sudoku_constants.Group_masks[end]
+                if ! has_group(variant, line) {
+                    break
+                }
+                mask = mask.Or(line)
+                result = append(result, region{mask, 45 * (end - start + 1)})
+            }
+        }
+    }
+    return result
+}
+
+func has_group(variant *sudoku_constants.Variant, mask uint128.Uint128) bool {
+    for _, group := range variant.Group_masks {
+        if group.Equals(mask) {
+            return true
+        }
+    }
+    return false
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func distinct(variant *sudoku_constants.Variant, cells [] int) bool {
+    // true if all 'cells' see each other
+    for i, cell := range cells {
+        for _, other := range cells[i + 1:] {
+            if variant.Neighbours[cell].And(
+                sudoku_constants.Powers[other]).IsZero() {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+func cells_mask(cells [] int) uint128.Uint128 {
+    var mask uint128.Uint128
+    for _, cell := range cells {
+        mask = mask.Or(sudoku_constants.Powers[cell])
+    }
+    return mask
+}
+
+func mask_cells(mask uint128.Uint128) [] int {
+    var cells [] int
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if ! mask.And(sudoku_constants.Powers[cell]).IsZero() {
+            cells = append(cells, cell)
+        }
+    }
+    return cells
+}
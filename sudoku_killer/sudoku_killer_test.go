@@ -0,0 +1,136 @@
+package sudoku_killer
+
+import (
+  "math/rand"
+  "os"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const SOLUTION = "483921657967345821251876493548132976729564138136798245372689514814253769695417382"
+
+// all digits 1..9, bit 'd' for digit 'd'
+const ALL_DIGITS = uint16(0x3fe)
+
+func TestMain(m *testing.M) {
+    sudoku_solver.Setup_solver_once()
+    os.Exit(m.Run())
+}
+
+func TestRestrict(t *testing.T) {
+    cases := [] struct {
+        name  string
+        sum   *Sum
+        given [] uint16 // nil: all digits
+        want  [] uint16 // nil: no combination left
+    } {
+        {"two cells 3", New_sum("cage", [] int {0, 1}, 3, true), nil,
+            [] uint16 {0x6, 0x6}},
+        {"three cells 24", New_sum("cage", [] int {0, 1, 2}, 24, true), nil,
+            [] uint16 {0x380, 0x380, 0x380}},
+        {"two cells 10", New_sum("cage", [] int {0, 1}, 10, true),
+            [] uint16 {0x3fe, 0x1c}, [] uint16 {0x1c0, 0x1c}},
+        {"no 5 and 5", New_sum("cage", [] int {0, 1}, 10, true),
+            [] uint16 {0x20, 0x3fe}, nil},
+        {"repeating 2", New_sum("outies", [] int {0, 30}, 2, false), nil,
+            [] uint16 {0x2, 0x2}},
+        {"distinct 2", New_sum("cage", [] int {0, 1}, 2, true), nil, nil},
+        {"too large", New_sum("cage", [] int {0, 1}, 18, true), nil, nil},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            candidates := make([] uint16, len(c.sum.Cells()))
+            for i := range candidates {
+                candidates[i] = ALL_DIGITS
+                if c.given != nil {
+                    candidates[i] = c.given[i]
+                }
+            }
+            ok := c.sum.Restrict(candidates)
+            switch {
+            case c.want == nil && ok:
+                t.Errorf("%03x left, want nothing", candidates)
+            case c.want == nil:
+            case ! ok:
+                t.Errorf("nothing left, want %03x", c.want)
+            default:
+                for i := range candidates {
+                    if candidates[i] != c.want[i] {
+                        t.Errorf("%03x left, want %03x", candidates, c.want)
+                        break
+                    }
+                }
+            }
+        })
+    }
+}
+
+func TestSound(t *testing.T) {
+    // cages drawn on a known solution, with a few cells left out, so that
+    // the 45 rule finds innies and outies: no constraint may remove a digit
+    // of the solution from any candidates containing it
+    var solution [81] int
+    for cell := range solution {
+        solution[cell] = int(SOLUTION[cell] - '0')
+    }
+    var cages [] Cage
+    for row := 0; row < Nine; row++ {
+        for _, cols := range [][] int {{0, 1}, {2, 3}, {4, 5}, {6}, {7, 8}} {
+            cage := Cage{}
+            for _, col := range cols {
+                cell := row * 9 + col
+                if row % 4 == 1 && col == 8 {
+                    continue
+                }
+                cage.Cells = append(cage.Cells, cell)
+                cage.Sum  += solution[cell]
+            }
+            if len(cage.Cells) > 0 {
+                cages = append(cages, cage)
+            }
+        }
+    }
+    variant, constraints, err := Killer(sudoku_constants.Classic, cages)
+    if err != nil {
+        t.Fatal(err)
+    }
+    techniques := map[string] int{}
+    for _, constraint := range constraints {
+        techniques[constraint.Name()]++
+    }
+    for _, technique := range [] string {"cage", "innies", "outies"} {
+        if techniques[technique] == 0 {
+            t.Errorf("no %s constraint to check", technique)
+        }
+    }
+
+    rng := rand.New(rand.NewSource(1))
+    for round := 0; round < 200; round++ {
+        for _, constraint := range constraints {
+            cells := constraint.Cells()
+            candidates := make([] uint16, len(cells))
+            for i, cell := range cells {
+                candidates[i] = uint16(rng.Intn(1 << 10)) & ALL_DIGITS |
+                    1 << solution[cell]
+            }
+            if ! constraint.Restrict(candidates) {
+                t.Fatalf("%s %v: nothing left", constraint.Name(), cells)
+            }
+            for i, cell := range cells {
+                if candidates[i] & (1 << solution[cell]) == 0 {
+                    t.Fatalf("%s %v: removes %d from cell %d",
+                        constraint.Name(), cells, solution[cell], cell)
+                }
+            }
+        }
+    }
+
+    solver := sudoku_solver.New_variant_solver(variant)
+    solver.Add_constraint(constraints...)
+    if err := solver.Verify(solution); err != nil {
+        t.Errorf("the solution breaks a constraint: %v", err)
+    }
+}
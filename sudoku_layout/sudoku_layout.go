@@ -0,0 +1,176 @@
+package sudoku_layout
+
+/* The layout model shared by the graphical renderers (SVG, PNG, PDF).
+ *
+ * 'Build' turns a picture description - givens, placed digits, candidates
+ * and an optional solver step - into a flat list of drawing items in
+ * a coordinate system with the origin in the top left corner. A renderer
+ * only has to know how to draw rectangles, lines and centred text.
+ *
+ * Highlights for a step:
+ * - the pattern cells are shaded
+ * - the cell where a digit gets placed is shaded green
+ * - eliminated candidates are drawn in red
+ */
+
+import (
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+type Kind int
+
+const (
+    RECT Kind = iota // filled rectangle from (X, Y) to (X2, Y2)
+    LINE             // line from (X, Y) to (X2, Y2)
+    TEXT             // 'Text' centred at (X, Y)
+)
+
+type Item struct {
+    Kind   Kind
+    X, Y   float64
+    X2, Y2 float64
+    Width  float64 // line width
+    Color  string  // "#rrggbb", fill colour for RECT
+    Text   string
+    Size   float64 // font size
+    Bold   bool
+}
+
+type Layout struct {
+    Width, Height float64
+    Items         [] Item
+}
+
+// Picture describes what to draw. 'Grid' contains the givens and all placed
+// digits, 'Locations' (one cell mask per digit) the candidates to show in
+// the empty cells, nil for none. 'Step' adds the highlights, nil for none.
+type Picture struct {
+    Givens    [81] int
+    Grid      [81] int
+    Locations *[10] uint128.Uint128
+    Step      *sudoku_solver.Step
+}
+
+// colours
+const (
+    BACKGROUND = "#ffffff"
+    INK        = "#000000"
+    THIN_LINE  = "#808080"
+    PLACED     = "#1f4fbf"
+    CANDIDATE  = "#606060"
+    ELIMINATED = "#d00000"
+    PATTERN    = "#ffe9a8"
+    TARGET     = "#c8f0c8"
+)
+
+func Build(picture Picture, cell_size float64) Layout {
+    // lay out 'picture' with cells of 'cell_size' units
+    margin := cell_size / 4
+    size   := 2 * margin + Nine * cell_size
+    layout := Layout{Width: size, Height: size}
+    step   := picture.Step
+
+    add := func(item Item) {
+        layout.Items = append(layout.Items, item)
+    }
+    cell_rect := func(cell int, color string) {
+        x := margin + float64(cell % 9) * cell_size
+        y := margin + float64(cell / 9) * cell_size
+        add(Item{Kind: RECT, X: x, Y: y, X2: x + cell_size, Y2: y + cell_size,
+            Color: color})
+    }
+
+    // background and shaded cells
+    add(Item{Kind: RECT, X: 0, Y: 0, X2: size, Y2: size, Color: BACKGROUND})
+    if step != nil {
+        for cell := 0; cell < Nine * Nine; cell++ {
+            if in_mask(step.Pattern, cell) {
+                cell_rect(cell, PATTERN)
+            }
+        }
+        if step.Cell >= 0 {
+            cell_rect(step.Cell, TARGET)
+        }
+    }
+
+    // grid lines, the thick ones around the boxes go on top
+    for _, thick := range [] bool {false, true} {
+        for i := 0; i <= Nine; i++ {
+            if (i % 3 == 0) != thick {
+                continue
+            }
+            pos   := margin + float64(i) * cell_size
+            width := cell_size / 40
+            color := THIN_LINE
+            if thick {
+                width = cell_size / 12
+                color = INK
+            }
+            add(Item{Kind: LINE, X: pos, Y: margin, X2: pos, Y2: size - margin,
+                Width: width, Color: color})
+            add(Item{Kind: LINE, X: margin, Y: pos, X2: size - margin, Y2: pos,
+                Width: width, Color: color})
+        }
+    }
+
+    // digits and candidates
+    for cell := 0; cell < Nine * Nine; cell++ {
+        x, y := cell_centre(cell, margin, cell_size)
+        switch {
+        case picture.Givens[cell] != 0:
+            add(Item{Kind: TEXT, X: x, Y: y, Text: digit(picture.Givens[cell]),
+                Size: cell_size * 0.7, Color: INK, Bold: true})
+        case picture.Grid[cell] != 0:
+            add(Item{Kind: TEXT, X: x, Y: y, Text: digit(picture.Grid[cell]),
+                Size: cell_size * 0.7, Color: PLACED})
+        default:
+            for d := 1; d <= Nine; d++ {
+                eliminated := step != nil && step.Digit == d &&
+                    in_mask(step.Eliminated, cell)
+                shown := picture.Locations != nil &&
+                    in_mask(picture.Locations[d], cell)
+                if ! shown && ! eliminated {
+                    continue
+                }
+                color := CANDIDATE
+                if eliminated {
+                    color = ELIMINATED
+                }
+                cx, cy := candidate_centre(cell, d, margin, cell_size)
+                add(Item{Kind: TEXT, X: cx, Y: cy, Text: digit(d),
+                    Size: cell_size * 0.28, Color: color, Bold: eliminated})
+            }
+        }
+    }
+    return layout
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func cell_centre(cell int, margin, cell_size float64) (float64, float64) {
+    return margin + (float64(cell % 9) + 0.5) * cell_size,
+        margin + (float64(cell / 9) + 0.5) * cell_size
+}
+
+func candidate_centre(cell, d int, margin, cell_size float64) (float64,
+    float64) {
+    // candidates are arranged like a phone keypad: 1 2 3 on top
+    x, y := cell_centre(cell, margin, cell_size)
+    return x + float64((d - 1) % 3 - 1) * cell_size * 0.3,
+        y + float64((d - 1) / 3 - 1) * cell_size * 0.3
+}
+
+func in_mask(mask uint128.Uint128, cell int) bool {
+    return ! mask.And(sudoku_constants.Powers[cell]).IsZero()
+}
+
+func digit(d int) string {
+    return string(rune('0' + d))
+}
@@ -0,0 +1,265 @@
+package sudoku_lines
+
+/* Line constraints for sudoku_solver: paths of cells through the grid, each
+ * step going to one of the 8 surrounding cells, with a rule for the digits
+ * along the path.
+ *
+ * - thermo: the digits strictly increase from the bulb (the first cell)
+ * - arrow: the digit in the circle (the first cell) is the sum of the
+ *   digits along the arrow; these may repeat, unless the groups forbid it
+ * - palindrome: the path reads the same in both directions
+ *
+ * The lines work on the candidates of their cells as bit masks, so they
+ * combine freely with each other, with the groups of any variant and with
+ * killer cages. New_line checks a path and makes the constraint for a kind
+ * given by name.
+ */
+
+import (
+  "fmt"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+// all digits 1..9, bit 'd' for digit 'd'
+const ALL_DIGITS = uint16(0x3fe)
+
+func New_line(kind string, cells [] int) (sudoku_solver.Constraint, error) {
+    // the constraint of 'kind' along 'cells'
+    if err := check_path(cells); err != nil {
+        return nil, fmt.Errorf("%s: %v", kind, err)
+    }
+    switch kind {
+    case "thermo":
+        if len(cells) > Nine {
+            return nil, fmt.Errorf("thermo: %d cells cannot increase",
+                len(cells))
+        }
+        return Thermo(cells), nil
+    case "arrow":
+        return Arrow(cells[0], cells[1:]), nil
+    case "palindrome":
+        return Palindrome(cells), nil
+    }
+    return nil, fmt.Errorf("unknown line %q", kind)
+}
+
+func check_path(cells [] int) error {
+    // at least 2 cells, each one next to the one before, none twice
+    if len(cells) < 2 {
+        return fmt.Errorf("%d cells, a line needs 2 or more", len(cells))
+    }
+    for i, cell := range cells {
+        if cell < 0 || cell >= Nine * Nine {
+            return fmt.Errorf("no cell %d", cell)
+        }
+        for _, other := range cells[:i] {
+            if other == cell {
+                return fmt.Errorf("cell %s twice", name(cell))
+            }
+        }
+        if i > 0 && ! adjacent(cells[i - 1], cell) {
+            return fmt.Errorf("%s does not touch %s", name(cell),
+                name(cells[i - 1]))
+        }
+    }
+    return nil
+}
+
+/*==============================================================================
+ *  thermometers
+ *==============================================================================
+ */
+type Thermometer struct {
+    cells [] int
+}
+
+func Thermo(cells [] int) *Thermometer {
+    return &Thermometer{cells: cells}
+}
+
+func (t *Thermometer) Name() string {
+    return "thermo"
+}
+
+func (t *Thermometer) Cells() [] int {
+    return t.cells
+}
+
+func (t *Thermometer) Restrict(candidates [] uint16) bool {
+    // every digit must be above the smallest candidate before it and below
+    // the largest one after it
+    for i := 1; i < len(candidates); i++ {
+        candidates[i] &= above(lowest(candidates[i - 1]))
+    }
+    for i := len(candidates) - 2; i >= 0; i-- {
+        candidates[i] &= below(highest(candidates[i + 1]))
+    }
+    return all_set(candidates)
+}
+
+/*==============================================================================
+ *  arrows
+ *==============================================================================
+ */
+type Sum_arrow struct {
+    cells [] int // the circle first, then the arrow
+}
+
+func Arrow(circle int, arrow [] int) *Sum_arrow {
+    return &Sum_arrow{cells: append([] int {circle}, arrow...)}
+}
+
+func (a *Sum_arrow) Name() string {
+    return "arrow"
+}
+
+func (a *Sum_arrow) Cells() [] int {
+    return a.cells
+}
+
+func (a *Sum_arrow) Restrict(candidates [] uint16) bool {
+    // the sums reachable along the arrow are kept as bit masks, bit 's' for
+    // sum 's'; the circle holds a digit, so no sum above 9 is of any use.
+    // 'before[i]': the sums of the arrow cells before cell 'i',
+    // 'after[i]': the sums cell 'i' and the ones after it must make up
+    arrow := candidates[1:]
+    n := len(arrow)
+    before := make([] uint16, n + 1)
+    after  := make([] uint16, n + 1)
+
+    before[0] = 1
+    for i, cands := range arrow {
+        before[i + 1] = add(before[i], cands)
+    }
+    candidates[0] &= before[n]
+
+    after[n] = candidates[0]
+    for i := n - 1; i >= 0; i-- {
+        after[i] = sub(after[i + 1], arrow[i])
+    }
+    for i := range arrow {
+        kept := uint16(0)
+        for d := 1; d <= Nine; d++ {
+            bit := uint16(1) << d
+            if arrow[i] & bit != 0 && (before[i] << d) & after[i + 1] != 0 {
+                kept |= bit
+            }
+        }
+        arrow[i] = kept
+    }
+    return all_set(candidates)
+}
+
+func add(sums uint16, digits uint16) uint16 {
+    // all sums of one from 'sums' and one of 'digits', up to 9
+    var result uint16
+    for d := 1; d <= Nine; d++ {
+        if digits & (1 << d) != 0 {
+            result |= sums << d
+        }
+    }
+    return result & (ALL_DIGITS | 1)
+}
+
+func sub(sums uint16, digits uint16) uint16 {
+    // all differences of one from 'sums' and one of 'digits', 0 or more
+    var result uint16
+    for d := 1; d <= Nine; d++ {
+        if digits & (1 << d) != 0 {
+            result |= sums >> d
+        }
+    }
+    return result
+}
+
+/*==============================================================================
+ *  palindromes
+ *==============================================================================
+ */
+type Palindrome_line struct {
+    cells [] int
+}
+
+func Palindrome(cells [] int) *Palindrome_line {
+    return &Palindrome_line{cells: cells}
+}
+
+func (p *Palindrome_line) Name() string {
+    return "palindrome"
+}
+
+func (p *Palindrome_line) Cells() [] int {
+    return p.cells
+}
+
+func (p *Palindrome_line) Restrict(candidates [] uint16) bool {
+    // mirrored cells hold the same digit
+    n := len(candidates)
+    for i := 0; i < n / 2; i++ {
+        both := candidates[i] & candidates[n - 1 - i]
+        candidates[i], candidates[n - 1 - i] = both, both
+    }
+    return all_set(candidates)
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func lowest(mask uint16) int {
+    for d := 1; d <= Nine; d++ {
+        if mask & (1 << d) != 0 {
+            return d
+        }
+    }
+    return Nine + 1
+}
+
+func highest(mask uint16) int {
+    for d := Nine; d >= 1; d-- {
+        if mask & (1 << d) != 0 {
+            return d
+        }
+    }
+    return 0
+}
+
+func above(d int) uint16 {
+    // the digits greater than 'd'
+    if d > Nine {
+        return 0
+    }
+    return ALL_DIGITS &^ (uint16(1) << (d + 1) - 1)
+}
+
+func below(d int) uint16 {
+    // the digits less than 'd'
+    if d < 1 {
+        return 0
+    }
+    return ALL_DIGITS & (uint16(1) << d - 1)
+}
+
+func all_set(candidates [] uint16) bool {
+    // false if a cell is left without candidates
+    for _, cands := range candidates {
+        if cands == 0 {
+            return false
+        }
+    }
+    return true
+}
+
+func adjacent(a, b int) bool {
+    // a king's move apart
+    dr, dc := a / 9 - b / 9, a % 9 - b % 9
+    return a != b && dr >= -1 && dr <= 1 && dc >= -1 && dc <= 1
+}
+
+func name(cell int) string {
+    return fmt.Sprintf("[%d%d]", cell / 9 + 1, cell % 9 + 1)
+}
@@ -0,0 +1,168 @@
+package sudoku_lines
+
+import (
+  "math/rand"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const SOLUTION = "483921657967345821251876493548132976729564138136798245372689514814253769695417382"
+
+func TestRestrict(t *testing.T) {
+    cases := [] struct {
+        name  string
+        line  sudoku_solver.Constraint
+        given [] uint16 // nil: all digits
+        want  [] uint16 // nil: nothing left
+    } {
+        {"thermo ends", Thermo([] int {0, 1, 2}), nil,
+            [] uint16 {0x0fe, 0x1fc, 0x3f8}},
+        {"thermo 5 in the middle", Thermo([] int {0, 1, 2}),
+            [] uint16 {ALL_DIGITS, 0x020, ALL_DIGITS},
+            [] uint16 {0x01e, 0x020, 0x3c0}},
+        {"thermo of 9", Thermo([] int {0, 1, 2, 3, 4, 5, 6, 7, 8}), nil,
+            [] uint16 {0x002, 0x004, 0x008, 0x010, 0x020, 0x040, 0x080, 0x100,
+                0x200}},
+        {"thermo falling", Thermo([] int {0, 1}), [] uint16 {0x200, 0x100},
+            nil},
+        {"arrow of 2", Arrow(0, [] int {1, 2}), nil,
+            [] uint16 {0x3fc, 0x1fe, 0x1fe}},
+        {"arrow to 3", Arrow(0, [] int {1, 2}),
+            [] uint16 {0x008, ALL_DIGITS, ALL_DIGITS},
+            [] uint16 {0x008, 0x006, 0x006}},
+        {"arrow too long", Arrow(0, [] int {1, 2}),
+            [] uint16 {0x002, ALL_DIGITS, ALL_DIGITS}, nil},
+        {"palindrome", Palindrome([] int {0, 1, 2}),
+            [] uint16 {0x00e, ALL_DIGITS, 0x01c},
+            [] uint16 {0x00c, ALL_DIGITS, 0x00c}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            candidates := make([] uint16, len(c.line.Cells()))
+            for i := range candidates {
+                candidates[i] = ALL_DIGITS
+                if c.given != nil {
+                    candidates[i] = c.given[i]
+                }
+            }
+            ok := c.line.Restrict(candidates)
+            switch {
+            case c.want == nil && ok:
+                t.Errorf("%03x left, want nothing", candidates)
+            case c.want == nil:
+            case ! ok:
+                t.Errorf("nothing left, want %03x", c.want)
+            default:
+                for i := range candidates {
+                    if candidates[i] != c.want[i] {
+                        t.Errorf("%03x left, want %03x", candidates, c.want)
+                        break
+                    }
+                }
+            }
+        })
+    }
+}
+
+func TestSound(t *testing.T) {
+    // thermometers and arrows drawn on a known solution: no line may remove
+    // a digit of the solution from any candidates containing it
+    var solution [81] int
+    for cell := range solution {
+        solution[cell] = int(SOLUTION[cell] - '0')
+    }
+    lines := thermometers(solution)
+    n_thermos := len(lines)
+    lines = append(lines, arrows(solution)...)
+    if n_thermos < 10 || len(lines) - n_thermos < 10 {
+        t.Fatalf("%d thermometers and %d arrows only", n_thermos,
+            len(lines) - n_thermos)
+    }
+
+    rng := rand.New(rand.NewSource(1))
+    for round := 0; round < 50; round++ {
+        for _, line := range lines {
+            cells := line.Cells()
+            candidates := make([] uint16, len(cells))
+            for i, cell := range cells {
+                candidates[i] = uint16(rng.Intn(1 << 10)) & ALL_DIGITS |
+                    1 << solution[cell]
+            }
+            if ! line.Restrict(candidates) {
+                t.Fatalf("%s %v: nothing left", line.Name(), cells)
+            }
+            for i, cell := range cells {
+                if candidates[i] & (1 << solution[cell]) == 0 {
+                    t.Fatalf("%s %v: removes %d from cell %d", line.Name(),
+                        cells, solution[cell], cell)
+                }
+            }
+        }
+    }
+}
+
+func thermometers(solution [81] int) [] sudoku_solver.Constraint {
+    // from every cell, step to the neighbour with the next larger digit
+    var result [] sudoku_solver.Constraint
+    for bulb := 0; bulb < Nine * Nine; bulb++ {
+        path := [] int {bulb}
+        for {
+            last, next := path[len(path) - 1], -1
+            for cell := 0; cell < Nine * Nine; cell++ {
+                if adjacent(last, cell) && solution[cell] > solution[last] &&
+                    (next < 0 || solution[cell] < solution[next]) {
+                    next = cell
+                }
+            }
+            if next < 0 {
+                break
+            }
+            path = append(path, next)
+        }
+        if len(path) >= 2 {
+            result = append(result, Thermo(path))
+        }
+    }
+    return result
+}
+
+func arrows(solution [81] int) [] sudoku_solver.Constraint {
+    // every path from a circle adding up to its digit, without turning
+    // back to the circle or to a cell passed before
+    var result [] sudoku_solver.Constraint
+    var walk func(circle int, path [] int, sum int)
+    walk = func(circle int, path [] int, sum int) {
+        last := path[len(path) - 1]
+        if sum == solution[circle] {
+            result = append(result, Arrow(circle,
+                append([] int(nil), path...)))
+            return
+        }
+        for cell := 0; cell < Nine * Nine; cell++ {
+            if ! adjacent(last, cell) || cell == circle || contains(path, cell) ||
+                sum + solution[cell] > solution[circle] {
+                continue
+            }
+            walk(circle, append(path, cell), sum + solution[cell])
+        }
+    }
+    for circle := 0; circle < Nine * Nine; circle += 7 {
+        for cell := 0; cell < Nine * Nine; cell++ {
+            if adjacent(circle, cell) && solution[cell] < solution[circle] {
+                walk(circle, [] int {cell}, solution[cell])
+            }
+        }
+    }
+    return result
+}
+
+func contains(cells [] int, cell int) bool {
+    for _, c := range cells {
+        if c == cell {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,271 @@
+package sudoku_parser
+
+/* Read puzzles in the common text formats and convert them to the
+ * 81 character line understood by sudoku_solver.Start_solver ('1'..'9' for
+ * givens, '.' for empty cells).
+ *
+ * Supported formats, detected automatically, even mixed in one text:
+ * - LINE   81 characters per line, optionally followed by a comment or other
+ *          fields (HoDoKu / .opt lines like "<puzzle> # comment" or
+ *          ":0000:x:<puzzle>:::")
+ * - GRID   9 lines of 9 characters
+ * - BOXED  9 lines with '|' separators and spaces between the cells,
+ *          separator lines made of '-', '+' and '|'
+ * - SS     Simple Sudoku .ss files: 3 characters between '|', '-' lines
+ * - SDK    SadMan .sdk files: '#' metadata lines, a '[Puzzle]' section with
+ *          9 lines of 9 characters, other sections (like '[State]') ignored
+ *
+ * Empty cells may be written as '.', '0', '*', '_', 'x' or 'X'.
+ * Comment lines start with '#', ';' or '//'. Anything else is an error.
+ */
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+)
+
+const Nine = 9
+
+type Format int
+
+const (
+    UNKNOWN Format = iota
+    LINE
+    GRID
+    BOXED
+    SS
+    SDK
+)
+
+var format_names = [] string {"unknown", "line", "grid", "boxed", "ss", "sdk"}
+
+func (f Format) String() string {
+    return format_names[f]
+}
+
+func Parse(text string) ([] string, error) {
+    // all puzzles in 'text'
+    return Parse_reader(strings.NewReader(text))
+}
+
+func Parse_one(text string) (string, error) {
+    // 'text' must contain exactly one puzzle
+    puzzles, err := Parse(text)
+    if err != nil {
+        return "", err
+    }
+    if len(puzzles) != 1 {
+        return "", fmt.Errorf("expected one puzzle, found %d", len(puzzles))
+    }
+    return puzzles[0], nil
+}
+
+func Parse_file(path string) ([] string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return Parse_reader(file)
+}
+
+func Parse_reader(r io.Reader) ([] string, error) {
+    var puzzles [] string
+    scanner := New_scanner(r)
+    for scanner.Scan() {
+        puzzles = append(puzzles, scanner.Puzzle())
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return puzzles, nil
+}
+
+// A Scanner reads puzzles one by one from a stream, so that large
+// collections need not be kept in memory:
+//
+//     scanner := sudoku_parser.New_scanner(file)
+//     for scanner.Scan() {
+//         use(scanner.Puzzle())
+//     }
+//     if err := scanner.Err(); err != nil { ... }
+type Scanner struct {
+    lines        *bufio.Scanner
+    line_no      int
+    puzzle       string
+    puzzle_line  int
+    err          error
+    skip_section bool
+}
+
+func New_scanner(r io.Reader) *Scanner {
+    return &Scanner{lines: bufio.NewScanner(r)}
+}
+
+func (s *Scanner) Puzzle() string {
+    // the puzzle found by the last call to Scan
+    return s.puzzle
+}
+
+func (s *Scanner) Line() int {
+    // the line number where the last puzzle starts
+    return s.puzzle_line
+}
+
+func (s *Scanner) Err() error {
+    return s.err
+}
+
+func (s *Scanner) Scan() bool {
+    // read line by line, a puzzle is either one line or collected from
+    // 9 grid rows
+    var grid strings.Builder
+    var grid_start int
+
+    if s.err != nil {
+        return false
+    }
+    for s.lines.Scan() {
+        s.line_no++
+        line := strings.TrimSpace(s.lines.Text())
+
+        // sdk sections: only '[Puzzle]' carries the puzzle
+        if strings.HasPrefix(line, "[") {
+            s.skip_section = ! strings.EqualFold(line, "[Puzzle]")
+            continue
+        }
+        if s.skip_section || line == "" || is_comment(line) ||
+            is_separator(line) {
+            continue
+        }
+
+        if puzzle, ok := find_line_puzzle(line); ok {
+            if grid.Len() > 0 {
+                s.err = fmt.Errorf("line %d: incomplete grid", grid_start)
+                return false
+            }
+            s.puzzle, s.puzzle_line = puzzle, s.line_no
+            return true
+        }
+
+        row, ok := grid_row(line)
+        if ! ok {
+            s.err = fmt.Errorf("line %d: cannot parse %q", s.line_no, line)
+            return false
+        }
+        if grid.Len() == 0 {
+            grid_start = s.line_no
+        }
+        grid.WriteString(row)
+        if grid.Len() == Nine * Nine {
+            s.puzzle, s.puzzle_line = grid.String(), grid_start
+            return true
+        }
+    }
+    if err := s.lines.Err(); err != nil {
+        s.err = err
+    } else if grid.Len() > 0 {
+        s.err = fmt.Errorf("line %d: incomplete grid", grid_start)
+    }
+    return false
+}
+
+func Detect(text string) Format {
+    // guess the format from the first line carrying puzzle data
+    for _, line := range strings.Split(text, "\n") {
+        line = strings.TrimSpace(line)
+        if strings.HasPrefix(line, "[") || (strings.HasPrefix(line, "#") &&
+            strings.Contains(text, "[Puzzle]")) {
+            return SDK
+        }
+        if line == "" || is_comment(line) || is_separator(line) {
+            continue
+        }
+        if _, ok := find_line_puzzle(line); ok {
+            return LINE
+        }
+        if _, ok := grid_row(line); ok {
+            if ! strings.Contains(line, "|") {
+                return GRID
+            } else if strings.Contains(line, " ") {
+                return BOXED
+            }
+            return SS
+        }
+        return UNKNOWN
+    }
+    return UNKNOWN
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func cell_char(char byte) (byte, bool) {
+    // normalise a cell character, '.' for empty cells
+    switch {
+    case '1' <= char && char <= '9':
+        return char, true
+    case char == '.' || char == '0' || char == '*' || char == '_' ||
+        char == 'x' || char == 'X':
+        return '.', true
+    }
+    return 0, false
+}
+
+func normalise(field string, length int) (string, bool) {
+    // 'field' must consist of exactly 'length' cell characters
+    if len(field) != length {
+        return "", false
+    }
+    result := make([] byte, length)
+    for i := 0; i < length; i++ {
+        char, ok := cell_char(field[i])
+        if ! ok {
+            return "", false
+        }
+        result[i] = char
+    }
+    return string(result), true
+}
+
+func find_line_puzzle(line string) (string, bool) {
+    // the first field of 81 cell characters, fields are separated by
+    // white space, ':', ';', ',' or '#'
+    fields := strings.FieldsFunc(line, func(r rune) bool {
+        return r == ' ' || r == '\t' || r == ':' || r == ';' || r == ',' ||
+            r == '#'
+    })
+    for _, field := range fields {
+        if puzzle, ok := normalise(field, Nine * Nine); ok {
+            return puzzle, true
+        }
+    }
+    return "", false
+}
+
+func grid_row(line string) (string, bool) {
+    // one row of a grid: 9 cell characters after removing separators
+    var row strings.Builder
+    for i := 0; i < len(line); i++ {
+        switch line[i] {
+        case ' ', '\t', '|', '+', '!':
+            continue
+        }
+        row.WriteByte(line[i])
+    }
+    return normalise(row.String(), Nine)
+}
+
+func is_comment(line string) bool {
+    return strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") ||
+        strings.HasPrefix(line, "//")
+}
+
+func is_separator(line string) bool {
+    // a line between bands, like "------+-------+------" or "==========="
+    return strings.Trim(line, "-+|= \t") == ""
+}
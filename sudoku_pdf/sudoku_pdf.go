@@ -0,0 +1,226 @@
+package sudoku_pdf
+
+/* Write a printable booklet of puzzles as PDF, using only the standard
+ * library.
+ *
+ * The puzzles are laid out 'per_page' to a page (A4, portrait), the
+ * solutions follow on the pages after the last puzzle. Every grid is drawn
+ * from the sudoku_layout model, so it looks the same as in the other
+ * renderers. The text uses the standard PDF fonts Helvetica and
+ * Helvetica-Bold, which need not be embedded.
+ *
+ * The solutions are found with sudoku_solver.Solve_puzzle, so
+ * sudoku_solver.Setup_solver_once must have been called.
+ */
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "math"
+  "strconv"
+  "strings"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_layout"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+// page size in points (A4) and the page margin
+const (
+    PAGE_WIDTH  = 595.0
+    PAGE_HEIGHT = 842.0
+    MARGIN      = 40.0
+    HEADER      = 30.0
+)
+
+func Write_booklet(w io.Writer, title string, puzzles [] string,
+    per_page int) error {
+    // puzzles first, then their solutions
+    var pages [] string
+    var givens, solutions [][81] int
+
+    if per_page < 1 {
+        per_page = 1
+    }
+    for i, puzzle := range puzzles {
+        if len(puzzle) < 81 {
+            return fmt.Errorf("puzzle %d: length not 81", i + 1)
+        }
+        solution, ok := sudoku_solver.Solve_puzzle(puzzle)
+        if ! ok {
+            return fmt.Errorf("puzzle %d: no solution", i + 1)
+        }
+        givens    = append(givens, puzzle2grid(puzzle))
+        solutions = append(solutions, solution)
+    }
+
+    n_pages := (len(puzzles) + per_page - 1) / per_page
+    for page := 0; page < n_pages; page++ {
+        pages = append(pages, page_content(title, "Puzzle", givens, givens,
+            page, per_page, len(pages) + 1))
+    }
+    for page := 0; page < n_pages; page++ {
+        pages = append(pages, page_content(title + " - solutions", "Solution",
+            givens, solutions, page, per_page, len(pages) + 1))
+    }
+    return write_pdf(w, pages)
+}
+
+/*==============================================================================
+ *  page layout
+ *==============================================================================
+ */
+func page_content(header, label string, givens, grids [][81] int,
+    page, per_page, page_no int) string {
+    // the content stream of one page with up to 'per_page' grids
+    var sb strings.Builder
+
+    cols := int(math.Ceil(math.Sqrt(float64(per_page))))
+    rows := (per_page + cols - 1) / cols
+    slot_w := (PAGE_WIDTH - 2 * MARGIN) / float64(cols)
+    slot_h := (PAGE_HEIGHT - 2 * MARGIN - HEADER) / float64(rows)
+    label_h := 16.0
+    size := math.Min(slot_w, slot_h - label_h) * 0.92
+    cell_size := size / (Nine + 0.5) // layout adds a margin of a quarter cell
+
+    text(&sb, PAGE_WIDTH / 2, MARGIN + HEADER / 2, 16, true, "#000000",
+        header)
+    text(&sb, PAGE_WIDTH / 2, PAGE_HEIGHT - MARGIN / 2, 9, false, "#000000",
+        strconv.Itoa(page_no))
+
+    for slot := 0; slot < per_page; slot++ {
+        index := page * per_page + slot
+        if index >= len(grids) {
+            break
+        }
+        x0 := MARGIN + float64(slot % cols) * slot_w + (slot_w - size) / 2
+        y0 := MARGIN + HEADER + float64(slot / cols) * slot_h + label_h
+
+        text(&sb, x0 + size / 2, y0 - label_h / 2, 11, true, "#000000",
+            fmt.Sprintf("%s %d", label, index + 1))
+        layout := sudoku_layout.Build(sudoku_layout.Picture{
+            Givens: givens[index], Grid: grids[index]}, cell_size)
+        draw_layout(&sb, layout, x0, y0)
+    }
+    return sb.String()
+}
+
+func draw_layout(sb *strings.Builder, layout sudoku_layout.Layout,
+    x0, y0 float64) {
+    // translate the layout items, (x0, y0) is the top left corner
+    for _, item := range layout.Items {
+        x1, y1 := x0 + item.X, y0 + item.Y
+        x2, y2 := x0 + item.X2, y0 + item.Y2
+        switch item.Kind {
+        case sudoku_layout.RECT:
+            fmt.Fprintf(sb, "%s rg %s %s %s %s re f\n", rgb(item.Color),
+                num(x1), num(PAGE_HEIGHT - y2), num(x2 - x1), num(y2 - y1))
+        case sudoku_layout.LINE:
+            fmt.Fprintf(sb, "%s RG %s w 2 J %s %s m %s %s l S\n",
+                rgb(item.Color), num(item.Width), num(x1),
+                num(PAGE_HEIGHT - y1), num(x2), num(PAGE_HEIGHT - y2))
+        case sudoku_layout.TEXT:
+            text(sb, x1, y1, item.Size, item.Bold, item.Color, item.Text)
+        }
+    }
+}
+
+func text(sb *strings.Builder, x, y, size float64, bold bool, color,
+    value string) {
+    // 'value' centred at (x, y). Digits and most letters in Helvetica are
+    // about 0.55 em wide, the digits are 0.7 em high.
+    font := "F1"
+    if bold {
+        font = "F2"
+    }
+    width := float64(len(value)) * 0.556 * size
+    fmt.Fprintf(sb, "BT /%s %s Tf %s rg %s %s Td (%s) Tj ET\n", font,
+        num(size), rgb(color), num(x - width / 2),
+        num(PAGE_HEIGHT - y - 0.35 * size), escape(value))
+}
+
+/*==============================================================================
+ *  PDF file structure
+ *==============================================================================
+ */
+func write_pdf(w io.Writer, pages [] string) error {
+    // objects: 1 catalog, 2 page tree, 3 and 4 fonts, then a page object
+    // and a content stream for every page
+    var buf bytes.Buffer
+    var offsets [] int
+
+    object := func(body string) {
+        offsets = append(offsets, buf.Len())
+        fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+    }
+
+    var kids [] string
+    for i := range pages {
+        kids = append(kids, fmt.Sprintf("%d 0 R", 5 + 2 * i))
+    }
+
+    buf.WriteString("%PDF-1.4\n")
+    object("<< /Type /Catalog /Pages 2 0 R >>")
+    object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+        strings.Join(kids, " "), len(pages)))
+    object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica " +
+        "/Encoding /WinAnsiEncoding >>")
+    object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold " +
+        "/Encoding /WinAnsiEncoding >>")
+    for i, content := range pages {
+        object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R " +
+            "/MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R " +
+            "/F2 4 0 R >> >> /Contents %d 0 R >>", num(PAGE_WIDTH),
+            num(PAGE_HEIGHT), 6 + 2 * i))
+        object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream",
+            len(content), content))
+    }
+
+    xref := buf.Len()
+    fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets) + 1)
+    for _, offset := range offsets {
+        fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+    }
+    fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n" +
+        "%d\n%%%%EOF\n", len(offsets) + 1, xref)
+
+    _, err := w.Write(buf.Bytes())
+    return err
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func rgb(color string) string {
+    // "#rrggbb" to "r g b" with components 0..1
+    value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+    if err != nil {
+        return "0 0 0"
+    }
+    return fmt.Sprintf("%s %s %s", num(float64(value >> 16 & 255) / 255),
+        num(float64(value >> 8 & 255) / 255), num(float64(value & 255) / 255))
+}
+
+func num(value float64) string {
+    return strings.TrimRight(strings.TrimRight(
+        fmt.Sprintf("%.3f", value), "0"), ".")
+}
+
+func escape(value string) string {
+    return strings.NewReplacer("\\", "\\\\", "(", "\\(", ")", "\\)").
+        Replace(value)
+}
+
+func puzzle2grid(puzzle string) [81] int {
+    var grid [81] int
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if '1' <= puzzle[cell] && puzzle[cell] <= '9' {
+            grid[cell] = int(puzzle[cell] - '0')
+        }
+    }
+    return grid
+}
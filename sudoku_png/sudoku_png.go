@@ -0,0 +1,240 @@
+package sudoku_png
+
+/* Render puzzles and solver steps as PNG images, with nothing but the
+ * standard library, e.g. for thumbnails on a headless build box.
+ *
+ * The drawing comes from sudoku_layout, like for the SVG and PDF renderers.
+ * Layout units are pixels. Lines and text are anti-aliased: every pixel is
+ * covered according to its distance from the nearest line segment.
+ *
+ * The digits come from a small bundled stroke font (see 'glyphs'): every
+ * digit is a set of polylines in a box 0.6 wide and 1.0 high, drawn with a
+ * pen whose width depends on the font size.
+ * sudoku_constants.Setup_sudoku_constants must have been called.
+ */
+
+import (
+  "image"
+  "image/color"
+  "image/draw"
+  "image/png"
+  "io"
+  "math"
+  "strconv"
+  "strings"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_layout"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+// size of a cell in pixels
+var CELL_SIZE = 40.0
+
+type point struct {
+    x, y float64
+}
+
+func Puzzle(givens [81] int) *image.RGBA {
+    // just the givens
+    return Render(sudoku_layout.Build(
+        sudoku_layout.Picture{Givens: givens, Grid: givens}, CELL_SIZE))
+}
+
+func Grid(givens, grid [81] int) *image.RGBA {
+    // givens and placed digits, e.g. a solution
+    return Render(sudoku_layout.Build(
+        sudoku_layout.Picture{Givens: givens, Grid: grid}, CELL_SIZE))
+}
+
+func Step(givens, grid [81] int, locations [10] uint128.Uint128,
+    step sudoku_solver.Step) *image.RGBA {
+    // the position before 'step' with its candidates and highlights
+    return Render(sudoku_layout.Build(sudoku_layout.Picture{Givens: givens,
+        Grid: grid, Locations: &locations, Step: &step}, CELL_SIZE))
+}
+
+func Write(w io.Writer, img image.Image) error {
+    return png.Encode(w, img)
+}
+
+func Render(layout sudoku_layout.Layout) *image.RGBA {
+    img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(layout.Width)),
+        int(math.Ceil(layout.Height))))
+
+    for _, item := range layout.Items {
+        col := parse_color(item.Color)
+        switch item.Kind {
+        case sudoku_layout.RECT:
+            rect := image.Rect(int(math.Round(item.X)), int(math.Round(item.Y)),
+                int(math.Round(item.X2)), int(math.Round(item.Y2)))
+            draw.Draw(img, rect, &image.Uniform{col}, image.Point{}, draw.Src)
+        case sudoku_layout.LINE:
+            stroke(img, [][] point {{{item.X, item.Y}, {item.X2, item.Y2}}},
+                item.Width, col)
+        case sudoku_layout.TEXT:
+            text(img, item, col)
+        }
+    }
+    return img
+}
+
+/*==============================================================================
+ *  drawing primitives
+ *==============================================================================
+ */
+func stroke(img *image.RGBA, lines [][] point, width float64,
+    col color.RGBA) {
+    // draw polylines with a round pen of 'width' pixels
+    half := width / 2
+    x_min, y_min := math.Inf(1), math.Inf(1)
+    x_max, y_max := math.Inf(-1), math.Inf(-1)
+    for _, line := range lines {
+        for _, p := range line {
+            x_min, x_max = math.Min(x_min, p.x), math.Max(x_max, p.x)
+            y_min, y_max = math.Min(y_min, p.y), math.Max(y_max, p.y)
+        }
+    }
+
+    bounds := img.Bounds()
+    for py := int(y_min - half - 1); py <= int(y_max + half + 1); py++ {
+        for px := int(x_min - half - 1); px <= int(x_max + half + 1); px++ {
+            if ! (image.Point{px, py}).In(bounds) {
+                continue
+            }
+            centre := point{float64(px) + 0.5, float64(py) + 0.5}
+            dist := math.Inf(1)
+            for _, line := range lines {
+                for i := 1; i < len(line); i++ {
+                    dist = math.Min(dist, distance(centre, line[i - 1],
+                        line[i]))
+                }
+            }
+            blend(img, px, py, col, half + 0.5 - dist)
+        }
+    }
+}
+
+func distance(p, a, b point) float64 {
+    // distance of 'p' from the segment a-b
+    dx, dy := b.x - a.x, b.y - a.y
+    length2 := dx * dx + dy * dy
+    t := 0.0
+    if length2 > 0 {
+        t = math.Max(0, math.Min(1, ((p.x - a.x) * dx + (p.y - a.y) * dy) /
+            length2))
+    }
+    nx, ny := a.x + t * dx, a.y + t * dy
+    return math.Hypot(p.x - nx, p.y - ny)
+}
+
+func blend(img *image.RGBA, px, py int, col color.RGBA, coverage float64) {
+    // paint 'col' over the pixel with the given coverage 0..1
+    if coverage <= 0 {
+        return
+    }
+    if coverage > 1 {
+        coverage = 1
+    }
+    old := img.RGBAAt(px, py)
+    mix := func(a, b uint8) uint8 {
+        return uint8(math.Round(float64(a) * (1 - coverage) +
+            float64(b) * coverage))
+    }
+    img.SetRGBA(px, py, color.RGBA{mix(old.R, col.R), mix(old.G, col.G),
+        mix(old.B, col.B), 255})
+}
+
+func text(img *image.RGBA, item sudoku_layout.Item, col color.RGBA) {
+    // draw the digits of 'item.Text' centred at (X, Y)
+    height := item.Size * 0.7
+    pen    := height * 0.11
+    if item.Bold {
+        pen = height * 0.16
+    }
+    advance := height * 0.8
+    x := item.X - advance * float64(len(item.Text)) / 2 +
+        (advance - height * 0.6) / 2
+    y := item.Y - height / 2
+
+    for _, char := range item.Text {
+        glyph, ok := glyphs[char]
+        if ! ok {
+            glyph = glyphs['?']
+        }
+        var lines [][] point
+        for _, line := range glyph {
+            var scaled [] point
+            for _, p := range line {
+                scaled = append(scaled, point{x + p.x * height,
+                    y + p.y * height})
+            }
+            lines = append(lines, scaled)
+        }
+        stroke(img, lines, pen, col)
+        x += advance
+    }
+}
+
+func parse_color(value string) color.RGBA {
+    // "#rrggbb"
+    rgb, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 16, 32)
+    if err != nil {
+        return color.RGBA{0, 0, 0, 255}
+    }
+    return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+}
+
+/*==============================================================================
+ *  the bundled stroke font
+ *==============================================================================
+ */
+var glyphs = map[rune] [][] point {
+    '0': {ellipse(0.3, 0.5, 0.26, 0.47)},
+    '1': {{{0.12, 0.2}, {0.34, 0.02}, {0.34, 0.98}}},
+    '2': {{{0.06, 0.22}, {0.14, 0.08}, {0.3, 0.02}, {0.46, 0.08},
+        {0.54, 0.22}, {0.5, 0.38}, {0.06, 0.98}, {0.58, 0.98}}},
+    '3': {{{0.06, 0.14}, {0.2, 0.03}, {0.34, 0.02}, {0.48, 0.08},
+        {0.53, 0.22}, {0.46, 0.38}, {0.26, 0.47}, {0.46, 0.54},
+        {0.56, 0.7}, {0.52, 0.87}, {0.36, 0.98}, {0.2, 0.97},
+        {0.04, 0.86}}},
+    '4': {{{0.44, 0.98}, {0.44, 0.02}, {0.03, 0.7}, {0.58, 0.7}}},
+    '5': {{{0.54, 0.02}, {0.12, 0.02}, {0.07, 0.45}, {0.24, 0.38},
+        {0.42, 0.4}, {0.54, 0.52}, {0.57, 0.7}, {0.52, 0.87},
+        {0.36, 0.98}, {0.2, 0.97}, {0.04, 0.86}}},
+    '6': six(false),
+    '7': {{{0.03, 0.02}, {0.57, 0.02}, {0.2, 0.98}}},
+    '8': {ellipse(0.3, 0.26, 0.22, 0.24), ellipse(0.3, 0.73, 0.27, 0.25)},
+    '9': six(true),
+    '?': {{{0.06, 0.2}, {0.18, 0.04}, {0.36, 0.02}, {0.52, 0.14},
+        {0.52, 0.32}, {0.3, 0.5}, {0.3, 0.72}}, {{0.3, 0.92}, {0.3, 0.98}}},
+}
+
+func ellipse(cx, cy, rx, ry float64) [] point {
+    return loop(cx, cy, rx, ry, 0)
+}
+
+func loop(cx, cy, rx, ry, start float64) [] point {
+    // a closed polyline of 24 segments, starting at angle 'start'
+    var line [] point
+    for i := 0; i <= 24; i++ {
+        angle := start + float64(i) * math.Pi / 12
+        line = append(line, point{cx + rx * math.Cos(angle),
+            cy + ry * math.Sin(angle)})
+    }
+    return line
+}
+
+func six(rotated bool) [][] point {
+    // a '6', rotated by 180 degrees it is a '9'
+    line := [] point {{0.52, 0.1}, {0.38, 0.02}, {0.22, 0.03}, {0.1, 0.14},
+        {0.04, 0.4}, {0.04, 0.68}}
+    line = append(line, loop(0.3, 0.72, 0.26, 0.26, math.Pi)...)
+    if rotated {
+        for i := range line {
+            line[i] = point{0.6 - line[i].x, 1 - line[i].y}
+        }
+    }
+    return [][] point {line}
+}
@@ -0,0 +1,218 @@
+package sudoku_sat
+
+/* Encode a sudoku as a SAT problem in DIMACS CNF format and read the model
+ * written by a SAT solver back into a grid.
+ *
+ * There is one boolean variable per digit per cell:
+ *     var(cell, d) = cell * 9 + d    (1 .. 729)
+ *
+ * The clauses follow the extended encoding:
+ * - every cell contains at least one and at most one digit
+ * - every group contains every digit at least once and at most once
+ * - cells which see each other without sharing a group (anti-knight,
+ *   killer cages) do not hold the same digit
+ * - every given is a unit clause
+ * - optionally every eliminated candidate is a negative unit clause
+ *
+ * The groups and the neighbours are taken from a sudoku_constants.Variant,
+ * classic sudoku for Write_cnf, so sudoku_constants.Setup_sudoku_constants
+ * must have been called. Constraints which are no neighbours, like cage
+ * sums, are not encoded.
+ */
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+)
+
+const Nine = 9
+const N_vars = Nine * Nine * Nine
+
+func Var(cell, digit int) int {
+    // the DIMACS variable for 'digit' in 'cell'
+    return cell * Nine + digit
+}
+
+func Write_cnf(w io.Writer, puzzle string) error {
+    // encode the rules and the givens of 'puzzle'
+    return write(w, sudoku_constants.Classic, puzzle, nil)
+}
+
+func Write_cnf_candidates(w io.Writer, puzzle string,
+    locations [10] uint128.Uint128) error {
+    // like Write_cnf, and add every candidate missing in 'locations' (one
+    // mask of cells per digit, as kept by sudoku_solver) as eliminated
+    return write(w, sudoku_constants.Classic, puzzle, &locations)
+}
+
+func Write_variant_cnf(w io.Writer, variant *sudoku_constants.Variant,
+    puzzle string) error {
+    // like Write_cnf, with the rules of 'variant'
+    return write(w, variant, puzzle, nil)
+}
+
+func Write_variant_cnf_candidates(w io.Writer,
+    variant *sudoku_constants.Variant, puzzle string,
+    locations [10] uint128.Uint128) error {
+    // like Write_cnf_candidates, with the rules of 'variant'
+    return write(w, variant, puzzle, &locations)
+}
+
+func write(w io.Writer, variant *sudoku_constants.Variant, puzzle string,
+    locations *[10] uint128.Uint128) error {
+    var clauses [][] int
+    if len(puzzle) < 81 {
+        return errors.New("puzzle length not 81")
+    }
+
+    // cells: at least one, at most one digit
+    for cell := 0; cell < Nine * Nine; cell++ {
+        var lits [] int
+        for d := 1; d <= Nine; d++ {
+            lits = append(lits, Var(cell, d))
+        }
+        clauses = append(clauses, exactly_one(lits)...)
+    }
+
+    // groups: every digit exactly once
+    for _, group := range variant.Group_masks {
+        for d := 1; d <= Nine; d++ {
+            var lits [] int
+            for cell := 0; cell < Nine * Nine; cell++ {
+                if ! group.And(sudoku_constants.Powers[cell]).IsZero() {
+                    lits = append(lits, Var(cell, d))
+                }
+            }
+            clauses = append(clauses, exactly_one(lits)...)
+        }
+    }
+
+    // neighbours outside of the groups: never the same digit
+    for cell := 0; cell < Nine * Nine; cell++ {
+        for other := cell + 1; other < Nine * Nine; other++ {
+            if variant.Neighbours[cell].And(
+                sudoku_constants.Powers[other]).IsZero() ||
+                share_group(variant, cell, other) {
+                continue
+            }
+            for d := 1; d <= Nine; d++ {
+                clauses = append(clauses,
+                    [] int {-Var(cell, d), -Var(other, d)})
+            }
+        }
+    }
+
+    // givens
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if '1' <= puzzle[cell] && puzzle[cell] <= '9' {
+            clauses = append(clauses,
+                [] int {Var(cell, int(puzzle[cell] - '0'))})
+        }
+    }
+
+    // eliminated candidates
+    if locations != nil {
+        for d := 1; d <= Nine; d++ {
+            for cell := 0; cell < Nine * Nine; cell++ {
+                if locations[d].And(sudoku_constants.Powers[cell]).IsZero() {
+                    clauses = append(clauses, [] int {-Var(cell, d)})
+                }
+            }
+        }
+    }
+
+    bw := bufio.NewWriter(w)
+    fmt.Fprintf(bw, "c sudoku %s\n", puzzle[:81])
+    fmt.Fprintf(bw, "c variant %s\n", variant.Name)
+    fmt.Fprintf(bw, "c var(cell, d) = cell * 9 + d, cell = 0..80, d = 1..9\n")
+    fmt.Fprintf(bw, "p cnf %d %d\n", N_vars, len(clauses))
+    for _, clause := range clauses {
+        for _, lit := range clause {
+            fmt.Fprintf(bw, "%d ", lit)
+        }
+        fmt.Fprintf(bw, "0\n")
+    }
+    return bw.Flush()
+}
+
+func share_group(variant *sudoku_constants.Variant, a, b int) bool {
+    // the pairs of a group have their clauses already
+    for _, g := range variant.Unit_index[a] {
+        if ! variant.Group_masks[g].And(sudoku_constants.Powers[b]).IsZero() {
+            return true
+        }
+    }
+    return false
+}
+
+func exactly_one(lits [] int) [][] int {
+    // one clause for 'at least one', pairwise clauses for 'at most one'
+    clauses := [][] int {append([] int {}, lits...)}
+    for i := 0; i < len(lits); i++ {
+        for j := i + 1; j < len(lits); j++ {
+            clauses = append(clauses, [] int {-lits[i], -lits[j]})
+        }
+    }
+    return clauses
+}
+
+func Read_model(r io.Reader) ([81] int, error) {
+    // read a model as written by minisat ("SAT" followed by the literals) or
+    // in the competition format ("s SATISFIABLE" and "v ..." lines)
+    var grid [81] int
+
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([] byte, 64 * 1024), 1024 * 1024)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "c") {
+            continue
+        }
+        if strings.HasPrefix(line, "s ") {
+            line = strings.TrimSpace(line[2:])
+        }
+        switch line {
+        case "SAT", "SATISFIABLE":
+            continue
+        case "UNSAT", "UNSATISFIABLE":
+            return grid, errors.New("no solution: unsatisfiable")
+        case "INDET", "UNKNOWN":
+            return grid, errors.New("no solution: solver gave up")
+        }
+        line = strings.TrimPrefix(line, "v ")
+
+        for _, field := range strings.Fields(line) {
+            lit, err := strconv.Atoi(field)
+            if err != nil {
+                return grid, fmt.Errorf("bad literal %q", field)
+            }
+            if lit <= 0 || lit > N_vars {
+                continue
+            }
+            cell  := (lit - 1) / Nine
+            digit := (lit - 1) % Nine + 1
+            if grid[cell] != 0 {
+                return grid, fmt.Errorf("cell %d has two digits", cell)
+            }
+            grid[cell] = digit
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return grid, err
+    }
+
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if grid[cell] == 0 {
+            return grid, fmt.Errorf("cell %d has no digit", cell)
+        }
+    }
+    return grid, nil
+}
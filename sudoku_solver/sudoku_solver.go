@@ -16,15 +16,55 @@ package sudoku_solver
  *
  * The input format for the puzzles to solve is 81 characters of 0..9 or '.'
  * if the line is longer than 81 characers, it gets trimmed to size.
+ * Any other character counts as an empty cell, use sudoku_parser to read
+ * and check the other common formats.
+ *
+ * The logic functions (locate, single, align) are complemented by a
+ * backtracking search for puzzles which need guessing. Solve_puzzle and
+ * Count_solutions can also be served by the Dancing Links solver in
+ * sudoku_dlx, selected with 'Backend'.
+ *
+ * All state of a puzzle being solved lives in a Solver. The package level
+ * functions work on a default solver and must not be called from more than
+ * one goroutine at a time; use New_solver to get one solver per goroutine.
+ *
+ * Solve_context and Count_context run under a context and Limits (search
+ * nodes, applications of the solver functions, a deadline), so that hostile
+ * puzzles cannot keep a CPU busy forever. When a limit is hit, they return
+ * what they have and the reason for stopping.
+ *
+ * The state of a Solver (contents, locations, unit_solved) can be saved and
+ * restored, in binary (MarshalBinary) or as JSON (MarshalJSON), e.g. to
+ * checkpoint a long analysis or to reproduce a bug report exactly.
+ *
+ * Verify and Verify_against are the check for a complete grid: a count of
+ * 81 from Start_solver only means that all cells are filled.
+ *
+ * A solver works on a sudoku_constants.Variant, classic sudoku unless it
+ * comes from New_variant_solver. For the classic groups 'align' uses the
+ * bisect tables, for all others it checks the crossings of the groups.
+ * The Dancing Links backend knows classic sudoku only.
+ *
+ * Constraints which are no groups, like the cages of killer sudoku, are
+ * added to a solver with Add_constraint. They work on the candidates of
+ * their cells as bit masks, 'constrain' is the solver function applying
+ * them after the others got stuck.
  */
 
 import (
+  "context"
+  "encoding/binary"
+  "encoding/json"
+  "errors"
   "fmt"
+  "strconv"
   "strings"
+  "time"
 
   // local
   "github.com/wplapper/go-sudoku3/uint128"
   "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_dlx"
 )
 
 
@@ -35,13 +75,57 @@ var DEBUG = 0
 var ONE = uint128.From64(1)
 var ALL_ONE = uint128.From64(1).Lsh(81).Sub(ONE)
 
-// dynamic variables
-var locations[10] uint128.Uint128
-var contents[81] int
-// 'unit_solved' is 2 dimensional slice containing true / false for the
-// combinations of all digits (1..9) for all groups(9+9+9)
-var unit_solved [][] bool
-var progress bool
+// solver backends for Solve_puzzle and Count_solutions
+const (
+    BITMASK = iota
+    DLX
+)
+var Backend = BITMASK
+
+// A Step explains one deduction of the solver functions. With RECORD set,
+// every step taken is appended to 'Steps'.
+type Step struct {
+    Technique  string          // "locate", "single", "align" or "guess"
+    Digit      int
+    Cell       int             // the cell where 'Digit' is placed, or -1
+    Group      int             // the group the deduction is based on, or -1
+    Other      int             // 'align': the group losing candidates, or -1
+    Pattern    uint128.Uint128 // the cells forming the pattern
+    Eliminated uint128.Uint128 // the cells where 'Digit' is removed
+    Variant    *sudoku_constants.Variant // for the group names, nil: classic
+}
+
+var RECORD = false
+var Steps [] Step
+
+// A Solver holds the state of one puzzle being solved. Solvers are
+// independent of each other, so several of them can work in parallel
+// goroutines. The package level functions (Start_solver, Solve_puzzle ...)
+// use a default solver, which picks up 'RECORD' and 'Backend' and leaves
+// its steps in 'Steps'.
+type Solver struct {
+    variant     *sudoku_constants.Variant
+    locations   [10] uint128.Uint128
+    contents    [81] int
+    // 'unit_solved' contains true / false for the combinations of all
+    // digits (1..9) for all groups of the variant (9+9+9 for classic)
+    unit_solved [10][sudoku_constants.MAX_GROUPS] bool
+    progress    bool
+    // 'broken' is set as soon as a contradiction shows up: a cell without
+    // candidates or a digit without a place in a group
+    broken      bool
+    // number of guesses made by 'search'
+    guesses     int
+    // the limits of Solve_context and Count_context
+    budget      budget
+    constraints [] Constraint
+
+    Backend     int     // BITMASK or DLX
+    Record      bool    // append every step taken to 'Steps'
+    Steps       [] Step
+}
+
+var default_solver *Solver
 
 // pointer arrays
 var p_all_powers[]          *uint128.Uint128
@@ -50,11 +134,6 @@ var p_Alignments_byline[][] *uint128.Uint128 // 18 * 12
 
 func Setup_solver_once() {
     sudoku_constants.Setup_sudoku_constants()
-    // setup unit_solved
-    unit_solved = make([][]bool, 10)
-    for i := range unit_solved {
-        unit_solved[i] = make([] bool, 27)
-    }
 
     //setup pointer list to sudoku_constants.Powers, needed for bisect
     p_all_powers = make([] *uint128.Uint128, 81)
@@ -89,29 +168,142 @@ func Setup_solver_once() {
                 &sudoku_constants.Alignments_byline[outer][inner].Mask
         }
     }
+    default_solver = New_solver()
+}
+
+func New_solver() *Solver {
+    // a solver of its own, Setup_solver_once must have been called
+    return New_variant_solver(sudoku_constants.Classic)
+}
+
+func New_variant_solver(variant *sudoku_constants.Variant) *Solver {
+    return &Solver{variant: variant, Backend: Backend}
+}
+
+func (sv *Solver) Variant() *sudoku_constants.Variant {
+    return sv.variant
+}
+
+// A Constraint restricts the digits of some cells beyond the groups of the
+// variant. 'Restrict' gets the candidates of Cells(), bit 'd' set for digit
+// 'd', and removes those which cannot be part of any solution; it returns
+// false if none is left. Name() is the technique of the steps it explains.
+type Constraint interface {
+    Name() string
+    Cells() [] int
+    Restrict(candidates [] uint16) bool
+}
+
+func (sv *Solver) Add_constraint(constraints ...Constraint) {
+    sv.constraints = append(sv.constraints, constraints...)
+}
+
+func (sv *Solver) Constraints() [] Constraint {
+    return sv.constraints
+}
+
+/*==============================================================================
+ *  package level functions, served by the default solver
+ *==============================================================================
+ */
+func use_default() *Solver {
+    default_solver.Record  = RECORD
+    default_solver.Backend = Backend
+    return default_solver
+}
+
+func keep_steps() {
+    Steps = default_solver.Steps
 }
 
 func Start_solver(puzzle string) int {
+    defer keep_steps()
+    return use_default().Start_solver(puzzle)
+}
+
+func Load_puzzle(puzzle string) int {
+    defer keep_steps()
+    return use_default().Load_puzzle(puzzle)
+}
+
+func Solve() int {
+    defer keep_steps()
+    return use_default().Solve()
+}
+
+func Contents() [81] int {
+    return default_solver.Contents()
+}
+
+func Locations() [10] uint128.Uint128 {
+    return default_solver.Locations()
+}
+
+func Broken() bool {
+    return default_solver.Broken()
+}
+
+func Solve_puzzle(puzzle string) ([81] int, bool) {
+    defer keep_steps()
+    return use_default().Solve_puzzle(puzzle)
+}
+
+func Count_solutions(puzzle string, limit int) int {
+    defer keep_steps()
+    return use_default().Count_solutions(puzzle, limit)
+}
+
+func Rate(puzzle string) Rating {
+    defer keep_steps()
+    return use_default().Rate(puzzle)
+}
+
+func Solve_context(ctx context.Context, puzzle string,
+    limits Limits) Outcome {
+    defer keep_steps()
+    return use_default().Solve_context(ctx, puzzle, limits)
+}
+
+func Count_context(ctx context.Context, puzzle string, limit int,
+    limits Limits) Outcome {
+    defer keep_steps()
+    return use_default().Count_context(ctx, puzzle, limit, limits)
+}
+
+/*==============================================================================
+ *  the solver methods
+ *==============================================================================
+ */
+func (sv *Solver) Start_solver(puzzle string) int {
+    // load 'puzzle' and let the solver functions do their work
+    sv.Load_puzzle(puzzle)
+    sv.Solve()
+    return sv.count_content()
+}
+
+func (sv *Solver) Load_puzzle(puzzle string) int {
     // reset contents, locations and unit_solved,
     // load initial values into locations etc.
     var digit int
 
     // reset locations to all possible candidates
     for d := 1; d <= Nine; d++ {
-        locations[d] = ALL_ONE
+        sv.locations[d] = ALL_ONE
     }
 
     // reset contents to zero
     for cell := 0; cell < Nine * Nine;  cell++ {
-        contents[cell] = 0
+        sv.contents[cell] = 0
     }
 
     // reset unit_solved to false
     for d := 1; d <= Nine; d++ {
-        for g := 0; g < Nine * 3; g++ {
-            unit_solved[d][g] = false
+        for g := range sv.variant.Group_masks {
+            sv.unit_solved[d][g] = false
         }
     }
+    sv.broken = false
+    sv.Steps  = nil
 
     // fill known places from puzzle
     length := len(puzzle)
@@ -122,17 +314,19 @@ func Start_solver(puzzle string) int {
     }
 
     for cell, char := range puzzle {
-        if '0' <= char && char <= '9' {
+        if '1' <= char && char <= '9' {
             digit = int(char) - 48 // 48 == '0'
-            place(digit, cell, sudoku_constants.Powers[cell])
+            if sv.locations[digit].And(sudoku_constants.Powers[cell]).IsZero() {
+                // the givens contradict each other
+                sv.broken = true
+            }
+            sv.place(digit, cell, sudoku_constants.Powers[cell])
         }
     }
-
-    Solve()
-    return count_content()
+    return sv.count_content()
 }
 
-func Solve() int {
+func (sv *Solver) Solve() int {
     // call the defined solver functions in sequence
     // if a solver succeeds, restart from the beginning
     var count int
@@ -140,15 +334,19 @@ func Solve() int {
 
     // need a type declaration for function pointers
     type SolveFunc func() bool
-    funcname  := [3] string {"locate", "single", "align"}
-    functions := [3] SolveFunc {locate, single, align}
+    funcname  := [4] string {"locate", "single", "align", "constrain"}
+    functions := [4] SolveFunc {sv.locate, sv.single, sv.align, sv.constrain}
 
-    progress = true
-    for progress {
-        count = count_content()
-        if count == 81 {
+    sv.progress = true
+    for sv.progress {
+        count = sv.count_content()
+        if count == 81 && ! sv.satisfied() {
+            sv.broken = true
+        }
+        if count == 81 || sv.broken || sv.over_budget() {
             return count
         }
+        sv.budget.steps++
 
         for pos, function := range functions {
             if DEBUG > 0 {
@@ -163,25 +361,47 @@ func Solve() int {
     } // end while
 
     // OnesCount
-    return count_content()
+    return sv.count_content()
+}
+
+func (sv *Solver) Contents() [81] int {
+    // a copy of the current grid, 0 for an empty cell
+    return sv.contents
 }
+
+func (sv *Solver) Locations() [10] uint128.Uint128 {
+    // a copy of the current candidates, one mask of cells per digit
+    return sv.locations
+}
+
+func (sv *Solver) Broken() bool {
+    // has the solver run into a contradiction
+    return sv.broken
+}
+
 /*==============================================================================
  *  solver functions
  *==============================================================================
  */
-func locate() bool {
+func (sv *Solver) locate() bool {
     // find digits which only live in one place in a group
     var mask uint128.Uint128
     var cell int
 
-    progress = false
+    sv.progress = false
+    groups := sv.variant.Group_masks
     for d := 1; d <= Nine; d++ {
-        for g := 0; g < Nine * 3; g++ {
-            if unit_solved[d][g] {
+        for g := range groups {
+            if sv.unit_solved[d][g] {
                 continue
             }
 
-            mask = locations[d].And(sudoku_constants.Group_masks[g])
+            mask = sv.locations[d].And(groups[g])
+            if mask.IsZero() {
+                // no place left for 'd' in 'g'
+                sv.broken = true
+                return sv.progress
+            }
             if ! (mask.And(mask.Sub(ONE))).IsZero() {
                 continue
             }
@@ -197,27 +417,29 @@ func locate() bool {
                 fmt.Printf("place with d=%d g=%2d for cell %s\n",
                     d, g, lin2name(cell))
             }
-            place(d, cell, mask)
+            sv.record(Step{Technique: "locate", Digit: d, Cell: cell, Group: g,
+                Other: -1, Pattern: groups[g]})
+            sv.place(d, cell, mask)
         }
     }
-    return progress
+    return sv.progress
 }
 
-func single() bool {
+func (sv *Solver) single() bool {
     // find cell which one have one candidate left in the 'cell'
     var count, dd int
     var bit uint128.Uint128
 
-    progress = false
+    sv.progress = false
     for cell := 0; cell < Nine * Nine; cell++ {
-        if contents[cell] != 0 {
+        if sv.contents[cell] != 0 {
             continue
         }
 
         count = 0
         bit = sudoku_constants.Powers[cell]
         for d := 1; d <= Nine; d++ {
-            if ! (locations[d].And(bit)).IsZero() {
+            if ! (sv.locations[d].And(bit)).IsZero() {
                 count++
                 dd = d
                 if count > 1 {
@@ -226,36 +448,47 @@ func single() bool {
             }
         }
 
+        if count == 0 {
+            // no candidate left for 'cell'
+            sv.broken = true
+            return sv.progress
+        }
+
         if count == 1 {
             if DEBUG > 0 {
                 fmt.Printf("single %d in %s\n", dd, lin2name(cell))
             }
             // found at single candidate 'dd' at 'cell'
-            place(dd, cell, bit)
+            sv.record(Step{Technique: "single", Digit: dd, Cell: cell, Group: -1,
+                Other: -1, Pattern: bit})
+            sv.place(dd, cell, bit)
         }
     }
-    return progress
+    return sv.progress
 }
 
-func align() bool {
+func (sv *Solver) align() bool {
     // check for candidates which live only in one row/column
     var mask, m uint128.Uint128
     var sm, c  int
 
-    progress = false
+    if sv.variant != sudoku_constants.Classic {
+        return sv.align_groups()
+    }
+    sv.progress = false
     for d := 1; d <= Nine; d++ {
         //try the columns / rows first
         for g := Nine; g < Nine * 3; g++ {
-            if unit_solved[d][g] {
+            if sv.unit_solved[d][g] {
                 continue
             }
-            mask = locations[d].And(sudoku_constants.Group_masks[g])
+            mask = sv.locations[d].And(sudoku_constants.Group_masks[g])
             c    = bisect(mask, p_Alignments_byline[g - 9])
             if c < 0 {
                 continue
             }
             sm = sudoku_constants.Alignments_byline[g - 9][c].S
-            m  = sudoku_constants.Group_masks[sm].And(locations[d]).
+            m  = sudoku_constants.Group_masks[sm].And(sv.locations[d]).
                 And(mask.Not())
             if m.IsZero() {
                 continue
@@ -265,23 +498,25 @@ func align() bool {
                 fmt.Printf("align1 d=%d at %2d X %2d for locs %s\n",
                     d, g, sm, strings.Join(locs, ","))
             }
-            unplace(d, m)
+            sv.record(Step{Technique: "align", Digit: d, Cell: -1, Group: g,
+                Other: sm, Pattern: mask, Eliminated: m})
+            sv.unplace(d, m)
         }
     }
 
     // go along and try the boxes
     for d := 1; d <= Nine; d++ {
         for s := 0; s < Nine; s++ {
-            if unit_solved[d][s] {
+            if sv.unit_solved[d][s] {
                 continue
             }
-            mask = locations[d].And(sudoku_constants.Group_masks[s])
+            mask = sv.locations[d].And(sudoku_constants.Group_masks[s])
             c    = bisect(mask, p_Alignments_bysqua[s])
             if c < 0 {
                 continue
             }
             sm = sudoku_constants.Alignments_bysqua[s][c].G
-            m  = sudoku_constants.Group_masks[sm].And(locations[d]).
+            m  = sudoku_constants.Group_masks[sm].And(sv.locations[d]).
                 And(mask.Not())
             if m.IsZero() {
                 continue
@@ -291,48 +526,743 @@ func align() bool {
                 fmt.Printf("align2 d=%d at %2d X %2d for locs %v\n",
                     d, s, sm, strings.Join(locs, ","))
             }
-            unplace(d, m)
+            sv.record(Step{Technique: "align", Digit: d, Cell: -1, Group: s,
+                Other: sm, Pattern: mask, Eliminated: m})
+            sv.unplace(d, m)
+        }
+    }
+    return sv.progress
+}
+
+func (sv *Solver) align_groups() bool {
+    // 'align' for any variant: if all candidates for 'd' in group 'g' lie
+    // in a crossing group 'h' as well, 'd' goes from the rest of 'h'
+    var mask, m uint128.Uint128
+
+    sv.progress = false
+    groups := sv.variant.Group_masks
+    for d := 1; d <= Nine; d++ {
+        for g := range groups {
+            if sv.unit_solved[d][g] {
+                continue
+            }
+            mask = sv.locations[d].And(groups[g])
+            for _, h := range sv.variant.Crossings[g] {
+                if ! mask.And(groups[h]).Equals(mask) {
+                    continue
+                }
+                m = groups[h].And(sv.locations[d]).And(mask.Not())
+                if m.IsZero() {
+                    continue
+                }
+                sv.record(Step{Technique: "align", Digit: d, Cell: -1,
+                    Group: g, Other: h, Pattern: mask, Eliminated: m})
+                sv.unplace(d, m)
+            }
+        }
+    }
+    return sv.progress
+}
+
+func (sv *Solver) constrain() bool {
+    // let the constraints remove candidates from their cells
+    var candidates, before [] uint16
+    var mask uint128.Uint128
+
+    sv.progress = false
+    for _, constraint := range sv.constraints {
+        cells := constraint.Cells()
+        candidates = candidates[:0]
+        for _, cell := range cells {
+            candidates = append(candidates, sv.candidates(cell))
+        }
+        before = append(before[:0], candidates...)
+        if ! constraint.Restrict(candidates) {
+            sv.broken = true
+            return sv.progress
+        }
+
+        for d := 1; d <= Nine; d++ {
+            bit := uint16(1) << d
+            mask = uint128.Uint128{}
+            for i, cell := range cells {
+                if before[i] & bit != 0 && candidates[i] & bit == 0 {
+                    mask = mask.Or(sudoku_constants.Powers[cell])
+                }
+            }
+            if mask.IsZero() {
+                continue
+            }
+            if DEBUG > 0 {
+                fmt.Printf("%s removes %d from %s\n", constraint.Name(), d,
+                    strings.Join(mask2cellnames(mask), ","))
+            }
+            sv.record(Step{Technique: constraint.Name(), Digit: d, Cell: -1,
+                Group: -1, Other: -1, Pattern: cells_mask(cells),
+                Eliminated: mask})
+            sv.unplace(d, mask)
+        }
+        for i := range cells {
+            if candidates[i] == 0 {
+                sv.broken = true
+                return sv.progress
+            }
+        }
+    }
+    return sv.progress
+}
+
+func (sv *Solver) satisfied() bool {
+    // false if the digits of a complete grid break a constraint
+    var candidates [] uint16
+    for _, constraint := range sv.constraints {
+        candidates = candidates[:0]
+        for _, cell := range constraint.Cells() {
+            candidates = append(candidates, uint16(1) << sv.contents[cell])
+        }
+        if ! constraint.Restrict(candidates) {
+            return false
+        }
+    }
+    return true
+}
+
+/*==============================================================================
+ *  search: backtracking for puzzles the solver functions cannot finish
+ *==============================================================================
+ */
+type state struct {
+    locations   [10] uint128.Uint128
+    contents    [81] int
+    unit_solved [10][sudoku_constants.MAX_GROUPS] bool
+}
+
+func (sv *Solver) Solve_puzzle(puzzle string) ([81] int, bool) {
+    // return the first solution found, false if there is none
+    var solution [81] int
+    if sv.Backend == DLX && sv.classic() {
+        return sudoku_dlx.Solve_puzzle(puzzle)
+    }
+
+    sv.Start_solver(puzzle)
+    count := sv.search(1, 0, &solution)
+    return solution, count > 0
+}
+
+func (sv *Solver) Count_solutions(puzzle string, limit int) int {
+    // count the solutions, stop at 'limit' (0 means count them all)
+    var solution [81] int
+    if sv.Backend == DLX && sv.classic() {
+        return sudoku_dlx.Count_solutions(puzzle, limit)
+    }
+
+    sv.Start_solver(puzzle)
+    return sv.search(limit, 0, &solution)
+}
+
+// A Rating tells how hard a puzzle is for the solver functions.
+// 'Level' is decided by the hardest technique needed:
+//     easy      locate only
+//     medium    single, or a technique weighing as much
+//     hard      align, or a technique weighing as much
+//     search    the solver functions get stuck, guessing is needed
+//     invalid   no solution
+//     multiple  more than one solution
+// 'Score' adds up Weights for every step taken and every guess made.
+type Rating struct {
+    Level   string
+    Score   int
+    Steps   int
+    Guesses int
+}
+
+var Weights = map[string] int {"locate": 1, "single": 2, "align": 5,
+    "guess": 20,
+    // the techniques of the constraints, see sudoku_killer, sudoku_lines
+    // and sudoku_dots
+    "cage": 3, "innies": 5, "outies": 5, "palindrome": 2, "thermo": 3,
+    "arrow": 4, "greater": 2, "kropki": 3, "xv": 3, "no kropki": 4,
+    "no xv": 4}
+
+func (sv *Solver) Rate(puzzle string) Rating {
+    var rating Rating
+    var solution [81] int
+
+    switch sv.Count_solutions(puzzle, 2) {
+    case 0:
+        rating.Level = "invalid"
+        return rating
+    case 2:
+        rating.Level = "multiple"
+        return rating
+    }
+
+    saved_record := sv.Record
+    sv.Record = true
+    sv.Start_solver(puzzle)
+    sv.Record = saved_record
+
+    rating.Level = "easy"
+    for _, step := range sv.Steps {
+        weight := Weights[step.Technique]
+        rating.Score += weight
+        if weight >= Weights["align"] {
+            rating.Level = "hard"
+        } else if weight >= Weights["single"] && rating.Level == "easy" {
+            rating.Level = "medium"
+        }
+    }
+    rating.Steps = len(sv.Steps)
+
+    if sv.count_content() < 81 {
+        sv.guesses = 0
+        sv.search(1, 0, &solution)
+        rating.Level   = "search"
+        rating.Guesses = sv.guesses
+        rating.Score  += sv.guesses * Weights["guess"]
+    }
+    return rating
+}
+
+func (sv *Solver) search(limit int, count int, first *[81] int) int {
+    // guess a digit in the cell with the fewest candidates, let the solver
+    // functions continue and recurse. 'count' is the number of solutions
+    // found so far, the first one is copied to 'first'.
+    if sv.broken || sv.over_budget() {
+        return count
+    }
+    sv.budget.nodes++
+    if sv.count_content() == 81 {
+        if count == 0 {
+            *first = sv.contents
+        }
+        return count + 1
+    }
+
+    cell, candidates := sv.best_cell()
+    saved := sv.save_state()
+    n_steps := len(sv.Steps)
+    for _, d := range candidates {
+        if DEBUG > 0 {
+            fmt.Printf("guess %d in %s\n", d, lin2name(cell))
+        }
+        sv.record(Step{Technique: "guess", Digit: d, Cell: cell, Group: -1,
+            Other: -1, Pattern: sudoku_constants.Powers[cell]})
+        sv.guesses++
+        sv.place(d, cell, sudoku_constants.Powers[cell])
+        sv.Solve()
+        count = sv.search(limit, count, first)
+        sv.restore_state(saved)
+        sv.Steps = sv.Steps[:n_steps]
+        if (limit > 0 && count >= limit) || sv.budget.stop != "" {
+            break
+        }
+    }
+    return count
+}
+
+func (sv *Solver) best_cell() (int, [] int) {
+    // find the empty cell with the fewest candidates
+    var candidates, best [] int
+    best_cell := -1
+
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if sv.contents[cell] != 0 {
+            continue
+        }
+        candidates = candidates[:0]
+        for d := 1; d <= Nine; d++ {
+            if ! sv.locations[d].And(sudoku_constants.Powers[cell]).IsZero() {
+                candidates = append(candidates, d)
+            }
+        }
+        if best_cell < 0 || len(candidates) < len(best) {
+            best_cell = cell
+            best = append(best[:0], candidates...)
+        }
+    }
+    return best_cell, best
+}
+
+func (sv *Solver) save_state() state {
+    var saved state
+    saved.locations = sv.locations
+    saved.contents  = sv.contents
+    saved.unit_solved = sv.unit_solved
+    return saved
+}
+
+func (sv *Solver) restore_state(saved state) {
+    sv.locations = saved.locations
+    sv.contents  = saved.contents
+    sv.unit_solved = saved.unit_solved
+    sv.broken = false
+}
+
+/*==============================================================================
+ *  budgets: stop a solver which takes too long
+ *==============================================================================
+ */
+
+// Limits for Solve_context, Count_context and Rate_context, 0 or the zero
+// time for none
+type Limits struct {
+    Max_nodes int       // search nodes, i.e. positions the search visits
+    Max_steps int       // applications of the solver functions
+    Deadline  time.Time // in addition to the deadline of the context
+}
+
+// why Solve_context or Count_context stopped
+const (
+    COMPLETE   = "complete"   // the search is done or found 'limit' solutions
+    CANCELLED  = "cancelled"  // the context was cancelled
+    DEADLINE   = "deadline"   // the deadline has passed
+    NODE_LIMIT = "node limit" // Max_nodes reached
+    STEP_LIMIT = "step limit" // Max_steps reached
+)
+
+// An Outcome is what Solve_context and Count_context found. 'Grid' is the
+// first solution if there is one, otherwise the cells the solver functions
+// could fill before searching: a partial result, correct as far as it goes
+// if the puzzle has a solution at all.
+type Outcome struct {
+    Reason string
+    Count  int      // solutions found
+    Grid   [81] int
+    Nodes  int
+    Steps  int
+}
+
+type budget struct {
+    active bool
+    ctx    context.Context
+    limits Limits
+    nodes  int
+    steps  int
+    stop   string // the reason for stopping, "" while within budget
+}
+
+func (sv *Solver) Solve_context(ctx context.Context, puzzle string,
+    limits Limits) Outcome {
+    // like Solve_puzzle, within 'limits'
+    return sv.Count_context(ctx, puzzle, 1, limits)
+}
+
+func (sv *Solver) Count_context(ctx context.Context, puzzle string, limit int,
+    limits Limits) Outcome {
+    // like Count_solutions, within 'limits'. Always uses the bitmask solver,
+    // the Dancing Links solver cannot be interrupted.
+    var outcome Outcome
+    sv.budget = budget{active: true, ctx: ctx, limits: limits}
+    defer func() {
+        sv.budget = budget{}
+    }()
+
+    sv.Start_solver(puzzle)
+    outcome.Count = sv.search(limit, 0, &outcome.Grid)
+
+    outcome.Reason = COMPLETE
+    if sv.budget.stop != "" {
+        outcome.Reason = sv.budget.stop
+    }
+    if outcome.Count == 0 {
+        // the search leaves the grid as it was after the solver functions
+        outcome.Grid = sv.contents
+    }
+    outcome.Nodes = sv.budget.nodes
+    outcome.Steps = sv.budget.steps
+    return outcome
+}
+
+func (sv *Solver) Rate_context(ctx context.Context, puzzle string,
+    limits Limits) (Rating, string) {
+    // like Rate, within 'limits'; the rating counts only if the reason
+    // returned is COMPLETE. Always uses the bitmask solver.
+    saved_backend := sv.Backend
+    sv.budget  = budget{active: true, ctx: ctx, limits: limits}
+    sv.Backend = BITMASK
+    defer func() {
+        sv.budget  = budget{}
+        sv.Backend = saved_backend
+    }()
+
+    rating := sv.Rate(puzzle)
+    if sv.budget.stop != "" {
+        return rating, sv.budget.stop
+    }
+    return rating, COMPLETE
+}
+
+func (sv *Solver) over_budget() bool {
+    // check the limits, remember the first one hit
+    b := &sv.budget
+    if ! b.active || b.stop != "" {
+        return b.stop != ""
+    }
+
+    switch {
+    case b.limits.Max_nodes > 0 && b.nodes >= b.limits.Max_nodes:
+        b.stop = NODE_LIMIT
+    case b.limits.Max_steps > 0 && b.steps >= b.limits.Max_steps:
+        b.stop = STEP_LIMIT
+    case ! b.limits.Deadline.IsZero() && time.Now().After(b.limits.Deadline):
+        b.stop = DEADLINE
+    case b.ctx != nil && b.ctx.Err() == context.DeadlineExceeded:
+        b.stop = DEADLINE
+    case b.ctx != nil && b.ctx.Err() != nil:
+        b.stop = CANCELLED
+    }
+    return b.stop != ""
+}
+
+/*==============================================================================
+ *  snapshots: save and restore the state of a solver
+ *==============================================================================
+ */
+
+// binary snapshot: "SDK" and a version byte, the number of groups of the
+// variant, 81 bytes contents, for the digits 1..9 the locations (16 bytes
+// little endian each) and unit_solved (8 bytes, bit 'g' for group 'g'),
+// one byte flags (1: broken)
+const SNAPSHOT_VERSION = 2
+const snapshot_size = 5 + 81 + Nine * 16 + Nine * 8 + 1
+
+var ErrSnapshot = errors.New("not a valid solver snapshot")
+
+// the JSON form: 'contents' as an 81 character line, the locations as
+// 21 hex digits, and the solved groups for every digit
+type json_snapshot struct {
+    Groups      int       `json:"groups"`
+    Contents    string    `json:"contents"`
+    Locations   [] string `json:"locations"`   // digits 1..9
+    Unit_solved [][] int  `json:"unit_solved"` // digits 1..9
+    Broken      bool      `json:"broken"`
+}
+
+func (sv *Solver) MarshalBinary() ([] byte, error) {
+    data := make([] byte, 0, snapshot_size)
+    data  = append(data, 'S', 'D', 'K', SNAPSHOT_VERSION,
+        byte(len(sv.variant.Group_masks)))
+    for cell := 0; cell < Nine * Nine; cell++ {
+        data = append(data, byte(sv.contents[cell]))
+    }
+    for d := 1; d <= Nine; d++ {
+        var mask [16] byte
+        sv.locations[d].PutBytes(mask[:])
+        data = append(data, mask[:]...)
+    }
+    for d := 1; d <= Nine; d++ {
+        data = binary.LittleEndian.AppendUint64(data, sv.solved_bits(d))
+    }
+    flags := byte(0)
+    if sv.broken {
+        flags |= 1
+    }
+    return append(data, flags), nil
+}
+
+func (sv *Solver) UnmarshalBinary(data [] byte) error {
+    var next state
+    if len(data) != snapshot_size || string(data[:3]) != "SDK" ||
+        data[3] != SNAPSHOT_VERSION || data[len(data) - 1] > 1 ||
+        int(data[4]) != len(sv.variant.Group_masks) {
+        return ErrSnapshot
+    }
+    pos := 5
+    for cell := 0; cell < Nine * Nine; cell++ {
+        next.contents[cell] = int(data[pos])
+        pos++
+    }
+    for d := 1; d <= Nine; d++ {
+        next.locations[d] = uint128.FromBytes(data[pos:pos + 16])
+        pos += 16
+    }
+    for d := 1; d <= Nine; d++ {
+        bits := binary.LittleEndian.Uint64(data[pos:])
+        if bits >> len(sv.variant.Group_masks) != 0 {
+            return ErrSnapshot
+        }
+        for g := range sv.variant.Group_masks {
+            next.unit_solved[d][g] = bits & (1 << g) != 0
+        }
+        pos += 8
+    }
+    return sv.restore_snapshot(next, data[pos] == 1)
+}
+
+func (sv *Solver) MarshalJSON() ([] byte, error) {
+    var out json_snapshot
+    line := make([] byte, Nine * Nine)
+    for cell := range line {
+        line[cell] = '.'
+        if sv.contents[cell] != 0 {
+            line[cell] = byte('0' + sv.contents[cell])
+        }
+    }
+    out.Contents = string(line)
+    for d := 1; d <= Nine; d++ {
+        mask := sv.locations[d]
+        out.Locations = append(out.Locations,
+            fmt.Sprintf("%05x%016x", mask.Hi, mask.Lo))
+        groups := [] int {}
+        for g := range sv.variant.Group_masks {
+            if sv.unit_solved[d][g] {
+                groups = append(groups, g)
+            }
+        }
+        out.Unit_solved = append(out.Unit_solved, groups)
+    }
+    out.Groups = len(sv.variant.Group_masks)
+    out.Broken = sv.broken
+    return json.Marshal(out)
+}
+
+func (sv *Solver) UnmarshalJSON(data [] byte) error {
+    var in json_snapshot
+    var next state
+    if err := json.Unmarshal(data, &in); err != nil {
+        return err
+    }
+    if len(in.Contents) != Nine * Nine || len(in.Locations) != Nine ||
+        len(in.Unit_solved) != Nine ||
+        in.Groups != len(sv.variant.Group_masks) {
+        return ErrSnapshot
+    }
+    for cell := 0; cell < Nine * Nine; cell++ {
+        switch char := in.Contents[cell]; {
+        case '1' <= char && char <= '9':
+            next.contents[cell] = int(char - '0')
+        case char != '.' && char != '0':
+            return ErrSnapshot
+        }
+    }
+    for d := 1; d <= Nine; d++ {
+        text := in.Locations[d - 1]
+        if len(text) != 21 {
+            return ErrSnapshot
+        }
+        hi, err1 := strconv.ParseUint(text[:5], 16, 64)
+        lo, err2 := strconv.ParseUint(text[5:], 16, 64)
+        if err1 != nil || err2 != nil {
+            return ErrSnapshot
+        }
+        next.locations[d] = uint128.New(lo, hi)
+        for _, g := range in.Unit_solved[d - 1] {
+            if g < 0 || g >= in.Groups {
+                return ErrSnapshot
+            }
+            next.unit_solved[d][g] = true
+        }
+    }
+    return sv.restore_snapshot(next, in.Broken)
+}
+
+func (sv *Solver) solved_bits(digit int) uint64 {
+    var bits uint64
+    for g := range sv.variant.Group_masks {
+        if sv.unit_solved[digit][g] {
+            bits |= 1 << g
+        }
+    }
+    return bits
+}
+
+func (sv *Solver) restore_snapshot(next state, broken bool) error {
+    // check the ranges, then take over the state. Steps recorded so far do
+    // not belong to the new state.
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if next.contents[cell] < 0 || next.contents[cell] > Nine {
+            return ErrSnapshot
+        }
+    }
+    for d := 1; d <= Nine; d++ {
+        if ! next.locations[d].And(ALL_ONE.Not()).IsZero() {
+            return ErrSnapshot
+        }
+    }
+    sv.restore_state(next)
+    sv.broken  = broken
+    sv.guesses = 0
+    sv.Steps   = nil
+    return nil
+}
+
+/*==============================================================================
+ *  steps: the explanations of the solver functions
+ *==============================================================================
+ */
+func (sv *Solver) record(step Step) {
+    if sv.Record {
+        if sv.variant != sudoku_constants.Classic {
+            step.Variant = sv.variant
+        }
+        sv.Steps = append(sv.Steps, step)
+    }
+}
+
+func (step Step) String() string {
+    // a one line explanation
+    switch step.Technique {
+    case "locate":
+        return fmt.Sprintf("%d in %s: only place in %s", step.Digit,
+            lin2name(step.Cell), step.group_name(step.Group))
+    case "single":
+        return fmt.Sprintf("%d in %s: only candidate left", step.Digit,
+            lin2name(step.Cell))
+    case "align":
+        return fmt.Sprintf("remove %d from %s: in %s it lives in %s only",
+            step.Digit, strings.Join(mask2cellnames(step.Eliminated), ","),
+            step.group_name(step.Group), step.group_name(step.Other))
+    case "guess":
+        return fmt.Sprintf("guess %d in %s", step.Digit, lin2name(step.Cell))
+    }
+    if ! step.Eliminated.IsZero() {
+        // a constraint: the pattern are its cells
+        return fmt.Sprintf("remove %d from %s: %s %s", step.Digit,
+            strings.Join(mask2cellnames(step.Eliminated), ","), step.Technique,
+            strings.Join(mask2cellnames(step.Pattern), ","))
+    }
+    return step.Technique
+}
+
+func (step Step) group_name(g int) string {
+    if step.Variant != nil {
+        return step.Variant.Group_names[g]
+    }
+    return Group_name(g)
+}
+
+func Group_name(g int) string {
+    // groups 0..8 are the boxes, 9..17 the rows, 18..26 the columns
+    switch {
+    case g < Nine:
+        return fmt.Sprintf("box %d", g + 1)
+    case g < Nine * 2:
+        return fmt.Sprintf("row %d", g - Nine + 1)
+    }
+    return fmt.Sprintf("column %d", g - Nine * 2 + 1)
+}
+
+/*==============================================================================
+ *  verification of complete grids
+ *==============================================================================
+ */
+func Verify(grid [81] int) error {
+    // nil if 'grid' is a valid solution: every cell holds a digit 1..9,
+    // and every group holds every digit exactly once
+    return verify(sudoku_constants.Classic, grid)
+}
+
+func (sv *Solver) Verify(grid [81] int) error {
+    // like Verify, for the variant and the constraints of the solver
+    var candidates [] uint16
+    if err := verify(sv.variant, grid); err != nil {
+        return err
+    }
+    for _, constraint := range sv.constraints {
+        candidates = candidates[:0]
+        for _, cell := range constraint.Cells() {
+            candidates = append(candidates, uint16(1) << grid[cell])
+        }
+        if ! constraint.Restrict(candidates) {
+            return fmt.Errorf("%s %s: broken", constraint.Name(),
+                strings.Join(mask2cellnames(cells_mask(constraint.Cells())),
+                ","))
+        }
+    }
+    return nil
+}
+
+func verify(variant *sudoku_constants.Variant, grid [81] int) error {
+    var masks [10] uint128.Uint128
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if grid[cell] < 1 || grid[cell] > Nine {
+            return fmt.Errorf("%s: no digit 1..9", lin2name(cell))
+        }
+        masks[grid[cell]] = masks[grid[cell]].Or(sudoku_constants.Powers[cell])
+    }
+    for g, group := range variant.Group_masks {
+        for d := 1; d <= Nine; d++ {
+            if n := masks[d].And(group).OnesCount(); n != 1 {
+                return fmt.Errorf("%s: digit %d %d times",
+                    variant.Group_names[g], d, n)
+            }
         }
     }
-    return progress
+    // neighbours beyond the groups, like in anti-knight sudoku
+    for cell := 0; cell < Nine * Nine; cell++ {
+        same := masks[grid[cell]].And(variant.Neighbours[cell])
+        if ! same.IsZero() {
+            return fmt.Errorf("%s: digit %d also in %s", lin2name(cell),
+                grid[cell], strings.Join(mask2cellnames(same), ","))
+        }
+    }
+    return nil
+}
+
+func Verify_against(puzzle string, grid [81] int) error {
+    // nil if 'grid' is a valid solution which keeps the givens of 'puzzle'
+    if len(puzzle) < 81 {
+        return fmt.Errorf("puzzle length not 81")
+    }
+    for cell := 0; cell < Nine * Nine; cell++ {
+        char := puzzle[cell]
+        if '1' <= char && char <= '9' && grid[cell] != int(char - '0') {
+            return fmt.Errorf("%s: given %c replaced by %d", lin2name(cell),
+                char, grid[cell])
+        }
+    }
+    return Verify(grid)
 }
 
 /*==============================================================================
  *  helpers for solver functions: place and unplace
  *==============================================================================
  */
-func place(digit int, cell int, bit uint128.Uint128) bool {
+func (sv *Solver) place(digit int, cell int, bit uint128.Uint128) bool {
     // put digit 'digit' into 'cell'
-    contents[cell] = digit
+    sv.contents[cell] = digit
     not_bit := bit.Not()
     var value [] int
 
     // remove candidates which have been fixed
     for d := 1; d <= Nine; d++ {
         if d != digit {
-            locations[d] = locations[d].And(not_bit)
+            sv.locations[d] = sv.locations[d].And(not_bit)
         } else {
-            locations[d] = locations[d].And(
-                sudoku_constants.Neighbours[cell].Not())
+            sv.locations[d] = sv.locations[d].And(
+                sv.variant.Neighbours[cell].Not())
         }
     }
 
     // set unit_solved
-    value = sudoku_constants.Unit_index[cell]
-    unit_solved[digit][value[0]] = true
-    unit_solved[digit][value[1]] = true
-    unit_solved[digit][value[2]] = true
-    progress = true
-    return progress
+    value = sv.variant.Unit_index[cell]
+    for _, g := range value {
+        sv.unit_solved[digit][g] = true
+    }
+    sv.progress = true
+    return sv.progress
 }
 
-func unplace(digit int, mask uint128.Uint128) bool {
+func (sv *Solver) candidates(cell int) uint16 {
+    // the candidates of 'cell' as a bit mask, bit 'd' for digit 'd'
+    var mask uint16
+    bit := sudoku_constants.Powers[cell]
+    for d := 1; d <= Nine; d++ {
+        if ! sv.locations[d].And(bit).IsZero() {
+            mask |= 1 << d
+        }
+    }
+    return mask
+}
+
+func (sv *Solver) unplace(digit int, mask uint128.Uint128) bool {
     // remove candidates from puzzle
-    if ! locations[digit].And(mask).IsZero() {
-        locations[digit] = locations[digit].And(mask.Not())
-        progress = true
+    if ! sv.locations[digit].And(mask).IsZero() {
+        sv.locations[digit] = sv.locations[digit].And(mask.Not())
+        sv.progress = true
     }
-    return progress
+    return sv.progress
 }
 
 /*==============================================================================
@@ -377,17 +1307,30 @@ func lin2name(cell int) string {
     return fmt.Sprintf("[%d%d]", cell / 9 + 1, cell % 9 + 1)
 }
 
-func count_content() int {
+func (sv *Solver) count_content() int {
     // count the number of soved cells in the current puzzle
     var count = 0
     for cell := 0; cell < Nine * Nine; cell++ {
-        if contents[cell] > 0 {
+        if sv.contents[cell] > 0 {
             count++
         }
     }
     return count
 }
 
+func (sv *Solver) classic() bool {
+    // plain classic sudoku, as the Dancing Links solver knows it
+    return sv.variant == sudoku_constants.Classic && len(sv.constraints) == 0
+}
+
+func cells_mask(cells [] int) uint128.Uint128 {
+    var mask uint128.Uint128
+    for _, cell := range cells {
+        mask = mask.Or(sudoku_constants.Powers[cell])
+    }
+    return mask
+}
+
 func mask2cellnames(mask uint128.Uint128) []string {
     // convert canddates into locations
     var locs [] string
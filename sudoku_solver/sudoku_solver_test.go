@@ -0,0 +1,95 @@
+package sudoku_solver
+
+import (
+  "os"
+  "strings"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+)
+
+// an easy puzzle and its solution
+const EASY = "..3.2.6..9..3.5..1..18.64....81.29..7.......8..67.82....26.95..8..2.3..9..5.1.3.."
+const EASY_SOLUTION = "483921657967345821251876493548132976729564138136798245372689514814253769695417382"
+
+func TestMain(m *testing.M) {
+    Setup_solver_once()
+    os.Exit(m.Run())
+}
+
+func TestVerify(t *testing.T) {
+    solution := puzzle2grid(EASY_SOLUTION)
+    swap := func(a, b int) [81] int {
+        grid := solution
+        grid[a], grid[b] = grid[b], grid[a]
+        return grid
+    }
+    with := func(cell, digit int) [81] int {
+        grid := solution
+        grid[cell] = digit
+        return grid
+    }
+    var latin [81] int // rows and columns are fine, the boxes are not
+    for cell := range latin {
+        latin[cell] = (cell / 9 + cell % 9) % Nine + 1
+    }
+    diagonal := New_variant_solver(sudoku_constants.Diagonal())
+    x_solution, ok := diagonal.Solve_puzzle(strings.Repeat(".", 81))
+    if ! ok {
+        t.Fatal("no solution for the empty diagonal grid")
+    }
+    against := func(grid [81] int) error {
+        return Verify_against(EASY, grid)
+    }
+
+    cases := [] struct {
+        name   string
+        verify func(grid [81] int) error
+        grid   [81] int
+        want   string // the start of the error, "": valid
+    } {
+        {"valid", Verify, solution, ""},
+        {"valid against", against, solution, ""},
+        {"row", Verify, swap(0, 9), "row 1: "},         // same box and column
+        {"column", Verify, swap(0, 1), "column 1: "},   // same box and row
+        {"box", Verify, latin, "box 1: "},
+        {"zero", Verify, with(40, 0), "[55]: no digit 1..9"},
+        {"ten", Verify, with(40, 10), "[55]: no digit 1..9"},
+        {"given", against, with(2, 4), "[13]: given 3 replaced by 4"},
+        {"given swapped", against, swap(2, 11), "[13]: given 3 replaced by 7"},
+        {"not a given", against, swap(0, 1), "column 1: "},
+        {"diagonal valid", diagonal.Verify, x_solution, ""},
+        {"diagonal classic", Verify, x_solution, ""},
+        {"diagonal", diagonal.Verify, solution, "diagonal: "},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            err := c.verify(c.grid)
+            switch {
+            case c.want == "" && err != nil:
+                t.Errorf("unexpected error: %v", err)
+            case c.want != "" && err == nil:
+                t.Errorf("no error, want %q", c.want)
+            case c.want != "" && ! strings.HasPrefix(err.Error(), c.want):
+                t.Errorf("error %q, want %q", err, c.want)
+            }
+        })
+    }
+}
+
+func TestVerify_against_short(t *testing.T) {
+    if err := Verify_against(EASY[:80], puzzle2grid(EASY_SOLUTION)); err == nil {
+        t.Error("a short puzzle is accepted")
+    }
+}
+
+func puzzle2grid(puzzle string) [81] int {
+    var grid [81] int
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if '1' <= puzzle[cell] && puzzle[cell] <= '9' {
+            grid[cell] = int(puzzle[cell] - '0')
+        }
+    }
+    return grid
+}
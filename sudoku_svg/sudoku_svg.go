@@ -0,0 +1,95 @@
+package sudoku_svg
+
+/* Render puzzles and solver steps as SVG.
+ *
+ * The drawing itself comes from sudoku_layout, this package only translates
+ * the layout items into SVG elements. sudoku_solver.Setup_solver_once (or at
+ * least sudoku_constants.Setup_sudoku_constants) must have been called.
+ */
+
+import (
+  "fmt"
+  "io"
+  "strings"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_layout"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+// size of a cell in SVG user units
+var CELL_SIZE = 40.0
+
+func Puzzle(givens [81] int) string {
+    // just the givens
+    return Render(sudoku_layout.Build(
+        sudoku_layout.Picture{Givens: givens, Grid: givens}, CELL_SIZE))
+}
+
+func Grid(givens, grid [81] int) string {
+    // givens and placed digits, e.g. a solution
+    return Render(sudoku_layout.Build(
+        sudoku_layout.Picture{Givens: givens, Grid: grid}, CELL_SIZE))
+}
+
+func Step(givens, grid [81] int, locations [10] uint128.Uint128,
+    step sudoku_solver.Step) string {
+    // the position before 'step' with its candidates and highlights
+    return Render(sudoku_layout.Build(sudoku_layout.Picture{Givens: givens,
+        Grid: grid, Locations: &locations, Step: &step}, CELL_SIZE))
+}
+
+func Render(layout sudoku_layout.Layout) string {
+    var sb strings.Builder
+    Write(&sb, layout)
+    return sb.String()
+}
+
+func Write(w io.Writer, layout sudoku_layout.Layout) error {
+    var sb strings.Builder
+
+    fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" `+
+        `width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+        num(layout.Width), num(layout.Height), num(layout.Width),
+        num(layout.Height))
+
+    for _, item := range layout.Items {
+        switch item.Kind {
+        case sudoku_layout.RECT:
+            fmt.Fprintf(&sb, `<rect x="%s" y="%s" width="%s" height="%s" `+
+                `fill="%s"/>`+"\n", num(item.X), num(item.Y),
+                num(item.X2 - item.X), num(item.Y2 - item.Y), item.Color)
+        case sudoku_layout.LINE:
+            fmt.Fprintf(&sb, `<line x1="%s" y1="%s" x2="%s" y2="%s" `+
+                `stroke="%s" stroke-width="%s" stroke-linecap="square"/>`+
+                "\n", num(item.X), num(item.Y), num(item.X2), num(item.Y2),
+                item.Color, num(item.Width))
+        case sudoku_layout.TEXT:
+            weight := "normal"
+            if item.Bold {
+                weight = "bold"
+            }
+            fmt.Fprintf(&sb, `<text x="%s" y="%s" font-family="sans-serif" `+
+                `font-size="%s" font-weight="%s" fill="%s" `+
+                `text-anchor="middle" dominant-baseline="central">%s</text>`+
+                "\n", num(item.X), num(item.Y), num(item.Size), weight,
+                item.Color, escape(item.Text))
+        }
+    }
+    sb.WriteString("</svg>\n")
+
+    _, err := io.WriteString(w, sb.String())
+    return err
+}
+
+func num(value float64) string {
+    // short numbers keep the files small
+    return strings.TrimRight(strings.TrimRight(
+        fmt.Sprintf("%.2f", value), "0"), ".")
+}
+
+func escape(text string) string {
+    return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").
+        Replace(text)
+}
@@ -0,0 +1,292 @@
+package sudoku_symmetry
+
+/* Symmetry analysis of puzzles and solution grids.
+ *
+ * - the clue pattern of a puzzle is checked against the classical geometric
+ *   symmetries (rotations, mirrors, diagonals); only the positions of the
+ *   givens count
+ * - a puzzle is automorphic if one of these symmetries combined with a
+ *   relabelling of the digits maps the givens onto themselves
+ * - a solution grid is searched for all its automorphisms: all 3359232
+ *   combinations of transposition, band, stack, row and column permutations
+ *   are tried, each one together with the digit relabelling it implies
+ * - the canonical form of a puzzle or grid is the lexicographically smallest
+ *   string (empty cells count as 0) over the same 3359232 transforms, with
+ *   the digits relabelled in order of their first appearance. Two puzzles
+ *   are equivalent if and only if their canonical forms are equal.
+ */
+
+import (
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_transform"
+)
+
+const Nine = 9
+
+type Symmetry struct {
+    Name      string
+    Transform sudoku_transform.Transform
+}
+
+// the geometric symmetries reported by name. A pattern with 'rotation 90'
+// also has 'rotation 180', both are reported.
+var Named_symmetries = [] Symmetry {
+    {"rotation 90",       sudoku_transform.Rotate(1)},
+    {"rotation 180",      sudoku_transform.Rotate(2)},
+    {"mirror horizontal", sudoku_transform.Mirror_horizontal()},
+    {"mirror vertical",   sudoku_transform.Mirror_vertical()},
+    {"diagonal",          sudoku_transform.Transpose()},
+    {"anti-diagonal",     sudoku_transform.Transpose().Then(
+                              sudoku_transform.Rotate(2))},
+}
+
+type Report struct {
+    Pattern       [] string // symmetries of the clue pattern
+    Puzzle        [] string // symmetries of the givens up to relabelling
+    Grid          [] string // named symmetries of the solution grid
+    Automorphisms [] sudoku_transform.Transform // all of the solution grid
+}
+
+func Analyse(puzzle string, solution [81] int) Report {
+    // collect all symmetry information for a puzzle and its solution
+    var report Report
+    report.Pattern = Pattern_symmetries(puzzle)
+    report.Puzzle  = Puzzle_symmetries(puzzle)
+    report.Automorphisms = Grid_automorphisms(solution)
+    for _, sym := range Named_symmetries {
+        for _, auto := range report.Automorphisms {
+            if sym.Transform.Cells == auto.Cells {
+                report.Grid = append(report.Grid, sym.Name)
+                break
+            }
+        }
+    }
+    return report
+}
+
+func Pattern_symmetries(puzzle string) [] string {
+    // which named symmetries map the clue positions onto themselves
+    var names [] string
+    grid := puzzle2grid(puzzle)
+
+    for _, sym := range Named_symmetries {
+        found := true
+        for cell := 0; cell < Nine * Nine; cell++ {
+            if (grid[cell] == 0) != (grid[sym.Transform.Cells[cell]] == 0) {
+                found = false
+                break
+            }
+        }
+        if found {
+            names = append(names, sym.Name)
+        }
+    }
+    return names
+}
+
+func Puzzle_symmetries(puzzle string) [] string {
+    // which named symmetries map the givens onto themselves, if the digits
+    // are allowed to be relabelled (automorphic puzzle)
+    var names [] string
+    grid := puzzle2grid(puzzle)
+
+    for _, sym := range Named_symmetries {
+        if _, ok := relabelling(grid, sym.Transform.Cells); ok {
+            names = append(names, sym.Name)
+        }
+    }
+    return names
+}
+
+func Grid_automorphisms(grid [81] int) [] sudoku_transform.Transform {
+    // find all non trivial transforms which map 'grid' onto itself
+    var result [] sudoku_transform.Transform
+    var cells [81] int
+    perms := line_permutations()
+
+    for transpose := 0; transpose < 2; transpose++ {
+        for _, row_perm := range perms {
+            for _, col_perm := range perms {
+                for cell := 0; cell < Nine * Nine; cell++ {
+                    r, c := cell / 9, cell % 9
+                    if transpose == 1 {
+                        r, c = c, r
+                    }
+                    cells[cell] = row_perm[r] * 9 + col_perm[c]
+                }
+                digits, ok := relabelling(grid, cells)
+                if !ok {
+                    continue
+                }
+                t := sudoku_transform.Transform{Cells: cells, Digits: digits}
+                if t.Is_identity() {
+                    continue
+                }
+                result = append(result, t)
+            }
+        }
+    }
+    return result
+}
+
+func Is_automorphic(grid [81] int) bool {
+    return len(Grid_automorphisms(grid)) > 0
+}
+
+func Canonical(puzzle string) (string, sudoku_transform.Transform) {
+    // the canonical form of 'puzzle' and the transform leading to it
+    var best, current [81] int
+    var best_t sudoku_transform.Transform
+    grid  := puzzle2grid(puzzle)
+    perms := line_permutations()
+    found := false
+
+    // inverse permutations: which source line ends up in line 'i'
+    inverses := make([][9] int, len(perms))
+    for i, perm := range perms {
+        for line := 0; line < Nine; line++ {
+            inverses[i][perm[line]] = line
+        }
+    }
+
+    for transpose := 0; transpose < 2; transpose++ {
+        for ri, row_inv := range inverses {
+            for ci, col_inv := range inverses {
+                var digits [10] int
+                next   := 1
+                better := ! found
+                worse  := false
+                for pos := 0; pos < Nine * Nine && ! worse; pos++ {
+                    r, c := row_inv[pos / 9], col_inv[pos % 9]
+                    if transpose == 1 {
+                        r, c = c, r
+                    }
+                    value := grid[r * 9 + c]
+                    if value != 0 {
+                        if digits[value] == 0 {
+                            digits[value] = next
+                            next++
+                        }
+                        value = digits[value]
+                    }
+                    current[pos] = value
+                    if ! better {
+                        if value < best[pos] {
+                            better = true
+                        } else if value > best[pos] {
+                            worse = true
+                        }
+                    }
+                }
+                if ! better {
+                    continue
+                }
+
+                // remember the new best and its transform
+                found = true
+                best  = current
+                for cell := 0; cell < Nine * Nine; cell++ {
+                    r, c := cell / 9, cell % 9
+                    if transpose == 1 {
+                        r, c = c, r
+                    }
+                    best_t.Cells[cell] = perms[ri][r] * 9 + perms[ci][c]
+                }
+                for d := 1; d <= Nine; d++ {
+                    if digits[d] == 0 {
+                        digits[d] = next
+                        next++
+                    }
+                }
+                best_t.Digits = digits
+            }
+        }
+    }
+
+    result := make([] byte, Nine * Nine)
+    for pos, value := range best {
+        result[pos] = '.'
+        if value != 0 {
+            result[pos] = byte('0' + value)
+        }
+    }
+    return string(result), best_t
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func relabelling(grid [81] int, cells [81] int) ([10] int, bool) {
+    // find the digit relabelling which maps 'grid' onto itself after moving
+    // the contents of cell 'c' to 'cells[c]'. Empty cells must stay empty.
+    var digits, inverse [10] int
+
+    for cell := 0; cell < Nine * Nine; cell++ {
+        from := grid[cell]
+        to   := grid[cells[cell]]
+        if (from == 0) != (to == 0) {
+            return digits, false
+        }
+        if digits[from] == 0 && inverse[to] == 0 {
+            digits[from] = to
+            inverse[to]  = from
+        } else if digits[from] != to || inverse[to] != from {
+            return digits, false
+        }
+    }
+
+    // digits missing in 'grid' are left in place
+    for d := 1; d <= Nine; d++ {
+        if digits[d] != 0 {
+            continue
+        }
+        for free := 1; free <= Nine; free++ {
+            if inverse[free] == 0 {
+                digits[d] = free
+                inverse[free] = d
+                break
+            }
+        }
+    }
+    return digits, true
+}
+
+func line_permutations() [][9] int {
+    // all 1296 permutations of rows which keep the bands intact:
+    // 6 permutations of the bands times 6 * 6 * 6 inside the bands
+    var result [][9] int
+    var line [9] int
+    perms := [6][3] int {
+        {0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0},
+    }
+
+    for _, band := range perms {
+        for _, p0 := range perms {
+            for _, p1 := range perms {
+                for _, p2 := range perms {
+                    inner := [3][3] int {p0, p1, p2}
+                    for r := 0; r < Nine; r++ {
+                        line[r] = band[r / 3] * 3 + inner[r / 3][r % 3]
+                    }
+                    result = append(result, line)
+                }
+            }
+        }
+    }
+    return result
+}
+
+func puzzle2grid(puzzle string) [81] int {
+    // convert the 81 character format, everything but 1..9 is empty
+    var grid [81] int
+    if len(puzzle) < 81 {
+        panic("puzzle length not 81")
+    }
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if '1' <= puzzle[cell] && puzzle[cell] <= '9' {
+            grid[cell] = int(puzzle[cell] - '0')
+        }
+    }
+    return grid
+}
@@ -0,0 +1,308 @@
+package sudoku_transform
+
+/* Validity preserving transformations of a sudoku grid.
+ *
+ * A sudoku stays a valid sudoku (with the same number of solutions) if
+ * - the grid is rotated, mirrored or transposed
+ * - the three bands (rows 1-3, 4-6, 7-9) or the three stacks (columns 1-3,
+ *   4-6, 7-9) are permuted
+ * - the three rows inside a band or the three columns inside a stack
+ *   are permuted
+ * - the digits 1..9 are relabelled
+ *
+ * A 'Transform' combines any number of these operations into one cell
+ * permutation and one digit permutation. Transforms can be composed with
+ * 'Then' and undone with 'Inverse', so a solution (or a single solution step,
+ * with Apply_step) found for a disguised puzzle can be mapped back to the
+ * original orientation.
+ */
+
+import (
+  "math/rand"
+
+  // local
+  "github.com/wplapper/go-sudoku3/uint128"
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+const Nine = 9
+
+// A Transform moves the content of cell 'c' to cell 'Cells[c]' and replaces
+// digit 'd' by digit 'Digits[d]'. Digits[0] is always 0 (empty cell).
+type Transform struct {
+    Cells  [81] int
+    Digits [10] int
+}
+
+/*==============================================================================
+ *  constructors
+ *==============================================================================
+ */
+func Identity() Transform {
+    // the transform which leaves everything in place
+    var t Transform
+    for cell := 0; cell < Nine * Nine; cell++ {
+        t.Cells[cell] = cell
+    }
+    for d := 0; d <= Nine; d++ {
+        t.Digits[d] = d
+    }
+    return t
+}
+
+func from_rc(move func(r, c int) (int, int)) Transform {
+    // build a cell transform from a function mapping (row, column) pairs
+    t := Identity()
+    for cell := 0; cell < Nine * Nine; cell++ {
+        r, c := move(cell / 9, cell % 9)
+        t.Cells[cell] = r * 9 + c
+    }
+    return t
+}
+
+func Rotate(quarter_turns int) Transform {
+    // rotate the grid clockwise by 'quarter_turns' * 90 degrees
+    quarter_turns = ((quarter_turns % 4) + 4) % 4
+    t := Identity()
+    for i := 0; i < quarter_turns; i++ {
+        t = t.Then(from_rc(func(r, c int) (int, int) { return c, 8 - r }))
+    }
+    return t
+}
+
+func Mirror_horizontal() Transform {
+    // swap left and right: column 1 <-> column 9
+    return from_rc(func(r, c int) (int, int) { return r, 8 - c })
+}
+
+func Mirror_vertical() Transform {
+    // swap top and bottom: row 1 <-> row 9
+    return from_rc(func(r, c int) (int, int) { return 8 - r, c })
+}
+
+func Transpose() Transform {
+    // mirror along the main diagonal: rows become columns
+    return from_rc(func(r, c int) (int, int) { return c, r })
+}
+
+func Permute_bands(perm [3] int) Transform {
+    // band 'b' (rows 3b..3b+2) moves to band 'perm[b]'
+    check_perm(perm[:], 0)
+    return from_rc(func(r, c int) (int, int) {
+        return perm[r / 3] * 3 + r % 3, c
+    })
+}
+
+func Permute_stacks(perm [3] int) Transform {
+    // stack 's' (columns 3s..3s+2) moves to stack 'perm[s]'
+    check_perm(perm[:], 0)
+    return from_rc(func(r, c int) (int, int) {
+        return r, perm[c / 3] * 3 + c % 3
+    })
+}
+
+func Permute_rows(band int, perm [3] int) Transform {
+    // inside band 'band' row 'i' (0..2) moves to row 'perm[i]'
+    check_perm(perm[:], 0)
+    check_range(band, 0, 2)
+    return from_rc(func(r, c int) (int, int) {
+        if r / 3 != band {
+            return r, c
+        }
+        return band * 3 + perm[r % 3], c
+    })
+}
+
+func Permute_columns(stack int, perm [3] int) Transform {
+    // inside stack 'stack' column 'i' (0..2) moves to column 'perm[i]'
+    check_perm(perm[:], 0)
+    check_range(stack, 0, 2)
+    return from_rc(func(r, c int) (int, int) {
+        if c / 3 != stack {
+            return r, c
+        }
+        return r, stack * 3 + perm[c % 3]
+    })
+}
+
+func Relabel(digits [10] int) Transform {
+    // replace digit 'd' by 'digits[d]', digits[1..9] must be a permutation
+    // of 1..9, digits[0] is ignored
+    check_perm(digits[1:], 1)
+    t := Identity()
+    for d := 1; d <= Nine; d++ {
+        t.Digits[d] = digits[d]
+    }
+    return t
+}
+
+func Random(rng *rand.Rand) Transform {
+    // a random combination of all operations, used to disguise a puzzle
+    var perm [3] int
+    var digits [10] int
+
+    random_perm := func() [3] int {
+        copy(perm[:], rng.Perm(3))
+        return perm
+    }
+
+    t := Identity()
+    if rng.Intn(2) == 1 {
+        t = t.Then(Transpose())
+    }
+    t = t.Then(Permute_bands(random_perm()))
+    t = t.Then(Permute_stacks(random_perm()))
+    for i := 0; i < 3; i++ {
+        t = t.Then(Permute_rows(i, random_perm()))
+        t = t.Then(Permute_columns(i, random_perm()))
+    }
+    for pos, d := range rng.Perm(Nine) {
+        digits[pos + 1] = d + 1
+    }
+    return t.Then(Relabel(digits))
+}
+
+/*==============================================================================
+ *  composition
+ *==============================================================================
+ */
+func (t Transform) Then(u Transform) Transform {
+    // first apply 't', then 'u'
+    var result Transform
+    for cell := 0; cell < Nine * Nine; cell++ {
+        result.Cells[cell] = u.Cells[t.Cells[cell]]
+    }
+    for d := 0; d <= Nine; d++ {
+        result.Digits[d] = u.Digits[t.Digits[d]]
+    }
+    return result
+}
+
+func (t Transform) Inverse() Transform {
+    // the transform which undoes 't'
+    var result Transform
+    for cell := 0; cell < Nine * Nine; cell++ {
+        result.Cells[t.Cells[cell]] = cell
+    }
+    for d := 0; d <= Nine; d++ {
+        result.Digits[t.Digits[d]] = d
+    }
+    return result
+}
+
+func (t Transform) Is_identity() bool {
+    return t == Identity()
+}
+
+/*==============================================================================
+ *  apply a transform
+ *==============================================================================
+ */
+func (t Transform) Apply_cell(cell int) int {
+    // where does 'cell' end up
+    return t.Cells[cell]
+}
+
+func (t Transform) Apply_digit(digit int) int {
+    // which digit replaces 'digit'
+    return t.Digits[digit]
+}
+
+func (t Transform) Apply_grid(grid [81] int) [81] int {
+    // transform a (partial) grid, 0 denotes an empty cell
+    var result [81] int
+    for cell := 0; cell < Nine * Nine; cell++ {
+        result[t.Cells[cell]] = t.Digits[grid[cell]]
+    }
+    return result
+}
+
+func (t Transform) Apply_puzzle(puzzle string) string {
+    // transform a puzzle in the 81 character format. The characters
+    // for empty cells ('0', '.') are moved along unchanged.
+    length := len(puzzle)
+    if  length < 81 {
+        panic("puzzle length not 81")
+    } else if length > 81 {
+        puzzle = puzzle[:81]
+    }
+
+    result := make([] byte, 81)
+    for cell := 0; cell < Nine * Nine; cell++ {
+        char := puzzle[cell]
+        if '1' <= char && char <= '9' {
+            char = byte('0' + t.Digits[char - '0'])
+        }
+        result[t.Cells[cell]] = char
+    }
+    return string(result)
+}
+
+func (t Transform) Apply_mask(mask uint128.Uint128) uint128.Uint128 {
+    // transform a set of cells
+    var result uint128.Uint128
+    for cell := 0; cell < Nine * Nine; cell++ {
+        if ! mask.And(sudoku_constants.Powers[cell]).IsZero() {
+            result = result.Or(sudoku_constants.Powers[t.Cells[cell]])
+        }
+    }
+    return result
+}
+
+func (t Transform) Apply_group(group int,
+    variant *sudoku_constants.Variant) int {
+    // the group the cells of 'group' end up in, -1 for none. A transform
+    // maps the groups of classic sudoku onto each other, the extra groups
+    // of a variant may have no image.
+    if group < 0 {
+        return -1
+    }
+    if variant == nil {
+        variant = sudoku_constants.Classic
+    }
+    image := t.Apply_mask(variant.Group_masks[group])
+    for g, mask := range variant.Group_masks {
+        if mask.Equals(image) {
+            return g
+        }
+    }
+    return -1
+}
+
+func (t Transform) Apply_step(step sudoku_solver.Step) sudoku_solver.Step {
+    // transform a solution step: cells, digit, groups and masks. A step
+    // found for a transformed puzzle is mapped back with the Inverse.
+    result := step
+    result.Digit = t.Digits[step.Digit]
+    if step.Cell >= 0 {
+        result.Cell = t.Cells[step.Cell]
+    }
+    result.Group      = t.Apply_group(step.Group, step.Variant)
+    result.Other      = t.Apply_group(step.Other, step.Variant)
+    result.Pattern    = t.Apply_mask(step.Pattern)
+    result.Eliminated = t.Apply_mask(step.Eliminated)
+    return result
+}
+
+/*==============================================================================
+ *  utility functions
+ *==============================================================================
+ */
+func check_perm(perm [] int, base int) {
+    // 'perm' must contain every value of base .. base+len(perm)-1 once
+    seen := make([] bool, len(perm))
+    for _, value := range perm {
+        check_range(value, base, base + len(perm) - 1)
+        if seen[value - base] {
+            panic("not a permutation")
+        }
+        seen[value - base] = true
+    }
+}
+
+func check_range(value, low, high int) {
+    if value < low || value > high {
+        panic("value out of range")
+    }
+}
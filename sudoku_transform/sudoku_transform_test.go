@@ -0,0 +1,83 @@
+package sudoku_transform
+
+import (
+  "math/rand"
+  "os"
+  "testing"
+
+  // local
+  "github.com/wplapper/go-sudoku3/sudoku_constants"
+  "github.com/wplapper/go-sudoku3/sudoku_solver"
+)
+
+// needs 'align' besides 'locate' and 'single'
+const HARD = "..25..........2.9.....6........7.8..7.4.9..1....3.6.4.2..4...3.4.....15.56.8..9.."
+
+func TestMain(m *testing.M) {
+    sudoku_solver.Setup_solver_once()
+    os.Exit(m.Run())
+}
+
+func TestApply_step(t *testing.T) {
+    // solve a disguised puzzle, map its steps back and check them against
+    // the solution of the original
+    solution, ok := sudoku_solver.Solve_puzzle(HARD)
+    if ! ok {
+        t.Fatal("no solution")
+    }
+    rng := rand.New(rand.NewSource(1))
+    for round := 0; round < 20; round++ {
+        transform := Random(rng)
+        back := transform.Inverse()
+
+        solver := sudoku_solver.New_solver()
+        solver.Record = true
+        if solver.Start_solver(transform.Apply_puzzle(HARD)) != 81 {
+            t.Fatal("the solver functions do not finish the puzzle")
+        }
+        aligns := 0
+        for _, found := range solver.Steps {
+            step := back.Apply_step(found)
+            check_step(t, step, solution)
+            if step.Technique == "align" {
+                aligns++
+            }
+        }
+        if aligns == 0 {
+            t.Errorf("round %d: no align step to check", round)
+        }
+    }
+}
+
+func check_step(t *testing.T, step sudoku_solver.Step, solution [81] int) {
+    groups := sudoku_constants.Group_masks
+    switch step.Technique {
+    case "locate":
+        if ! step.Pattern.Equals(groups[step.Group]) ||
+            step.Pattern.And(sudoku_constants.Powers[step.Cell]).IsZero() {
+            t.Errorf("%v: cell not in the group", step)
+        }
+        fallthrough
+    case "single":
+        if solution[step.Cell] != step.Digit {
+            t.Errorf("%v: the solution has %d", step, solution[step.Cell])
+        }
+    case "align":
+        if step.Group < 0 || step.Other < 0 {
+            t.Errorf("%v: groups not mapped", step)
+            return
+        }
+        if ! step.Eliminated.And(groups[step.Other].Not()).IsZero() ||
+            ! step.Eliminated.And(groups[step.Group]).IsZero() {
+            t.Errorf("%v: eliminated cells outside of the pattern", step)
+        }
+        for cell := 0; cell < Nine * Nine; cell++ {
+            if ! step.Eliminated.And(sudoku_constants.Powers[cell]).IsZero() &&
+                solution[cell] == step.Digit {
+                t.Errorf("%v: removes a digit of the solution", step)
+            }
+        }
+    default:
+        t.Errorf("unexpected step %v", step)
+    }
+}